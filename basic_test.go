@@ -513,15 +513,10 @@ func TestProof(t *testing.T) {
 		proof, err := tree.GetMembershipProof(key)
 		assert.NoError(t, err)
 		assert.Equal(t, value, proof.GetExist().Value)
+		// The proofs of the unsaved items verify too, against the working tree.
 		res, err := tree.VerifyMembership(proof, key)
 		assert.NoError(t, err)
-		value2, err := tree.ImmutableTree.Get(key)
-		assert.NoError(t, err)
-		if value2 != nil {
-			assert.True(t, res)
-		} else {
-			assert.False(t, res)
-		}
+		assert.True(t, res)
 		return false
 	})
 }
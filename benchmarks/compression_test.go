@@ -0,0 +1,95 @@
+package benchmarks
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/cosmos/iavl"
+)
+
+// compressibleBytes returns a value resembling typical application state, i.e. a mix of
+// repeated field names and random data, which compresses reasonably well.
+func compressibleBytes(r *rand.Rand, length int) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, length))
+	for buf.Len() < length {
+		fmt.Fprintf(buf, `{"denom":"uatom","amount":"%d"}`, r.Int63())
+	}
+	return buf.Bytes()[:length]
+}
+
+// storedBytes returns the total size of all keys and values in the database.
+func storedBytes(b *testing.B, d db.DB) int {
+	itr, err := d.Iterator(nil, nil)
+	require.NoError(b, err)
+	defer itr.Close()
+
+	size := 0
+	for ; itr.Valid(); itr.Next() {
+		size += len(itr.Key()) + len(itr.Value())
+	}
+	require.NoError(b, itr.Error())
+	return size
+}
+
+// BenchmarkCompression compares the stored size, the commit time and the uncached read time of
+// trees using each of the available compression codecs.
+func BenchmarkCompression(b *testing.B) {
+	const (
+		initSize  = 10000
+		blockSize = 100
+		keyLen    = 16
+	)
+	codecs := []iavl.Compression{iavl.NoCompression, iavl.SnappyCompression, iavl.ZstdCompression}
+
+	for _, dataLen := range []int{40, 400} {
+		for _, codec := range codecs {
+			codec, dataLen := codec, dataLen
+			b.Run(fmt.Sprintf("%s-%d", codec, dataLen), func(b *testing.B) {
+				r := rand.New(rand.NewSource(49872768940))
+				d := db.NewMemDB()
+				opts := iavl.DefaultOptions()
+				opts.Compression = codec
+				t, err := iavl.NewMutableTreeWithOpts(d, 0, &opts, false)
+				require.NoError(b, err)
+
+				keys := make([][]byte, initSize)
+				for i := range keys {
+					keys[i] = randBytes(keyLen)
+					_, err = t.Set(keys[i], compressibleBytes(r, dataLen))
+					require.NoError(b, err)
+				}
+				_, _, err = t.SaveVersion()
+				require.NoError(b, err)
+				stored := float64(storedBytes(b, d))
+
+				b.Run("commit", func(b *testing.B) {
+					b.ReportAllocs()
+					b.ReportMetric(stored, "stored-bytes")
+					for i := 1; i <= b.N; i++ {
+						_, err := t.Set(keys[r.Intn(initSize)], compressibleBytes(r, dataLen))
+						require.NoError(b, err)
+						if i%blockSize == 0 {
+							commitTree(b, t)
+						}
+					}
+				})
+
+				b.Run("query-slow", func(b *testing.B) {
+					b.ReportAllocs()
+					b.ReportMetric(stored, "stored-bytes")
+					itree, err := t.GetImmutable(t.Version())
+					require.NoError(b, err)
+					for i := 0; i < b.N; i++ {
+						_, _, err := itree.GetWithIndex(keys[r.Intn(initSize)])
+						require.NoError(b, err)
+					}
+				})
+			})
+		}
+	}
+}
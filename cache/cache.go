@@ -9,6 +9,10 @@ import (
 // Node represents a node eligible for caching.
 type Node interface {
 	GetKey() []byte
+
+	// EncodedSize returns the size of the node's encoding in bytes. Together with the
+	// length of the key, it is used to account for the node in byte-bounded caches.
+	EncodedSize() int
 }
 
 // Cache is an in-memory structure to persist nodes for quick access.
@@ -16,7 +20,8 @@ type Node interface {
 // cache implementation.
 type Cache interface {
 	// Adds node to cache. If full and had to remove the oldest element,
-	// returns the oldest, otherwise nil.
+	// returns the oldest, otherwise nil. A byte-bounded cache may have to
+	// remove several elements, in which case only the oldest is returned.
 	// CONTRACT: node can never be nil. Otherwise, cache panics.
 	Add(node Node) Node
 
@@ -38,20 +43,24 @@ type Cache interface {
 // The motivation for using a custom cache implementation is to
 // allow for a custom max policy.
 //
-// Currently, the cache maximum is implemented in terms of the
-// number of nodes which is not intuitive to configure.
-// Instead, we are planning to add a byte maximum.
+// The cache maximum is either the number of nodes, see New, or
+// the total size of the cached nodes in bytes, see NewWithBytesLimit.
+// The latter is more intuitive to configure, since node sizes
+// vary widely between applications.
 // The alternative implementations do not allow for
 // customization and the ability to estimate the byte
 // size of the cache.
 type lruCache struct {
 	dict            map[string]*list.Element // FastNode cache.
 	maxElementCount int                      // FastNode the maximum number of nodes in the cache.
+	maxBytes        int                      // The maximum total size of the nodes in the cache, if positive.
+	curBytes        int                      // The current total size of the nodes in the cache.
 	ll              *list.List               // LRU queue of cache elements. Used for deletion.
 }
 
 var _ Cache = (*lruCache)(nil)
 
+// New returns an LRU cache holding at most maxElementCount nodes.
 func New(maxElementCount int) Cache {
 	return &lruCache{
 		dict:            make(map[string]*list.Element),
@@ -60,18 +69,41 @@ func New(maxElementCount int) Cache {
 	}
 }
 
+// NewWithBytesLimit returns an LRU cache holding nodes whose total size,
+// as reported by Size, does not exceed maxBytes.
+func NewWithBytesLimit(maxBytes int) Cache {
+	return &lruCache{
+		dict:     make(map[string]*list.Element),
+		maxBytes: maxBytes,
+		ll:       list.New(),
+	}
+}
+
+// Size returns the number of bytes a node accounts for in a byte-bounded cache.
+func Size(node Node) int {
+	return len(node.GetKey()) + node.EncodedSize()
+}
+
 func (c *lruCache) Add(node Node) Node {
 	keyStr := ibytes.UnsafeBytesToStr(node.GetKey())
 	if e, exists := c.dict[keyStr]; exists {
 		c.ll.MoveToFront(e)
-		old := e.Value
+		old := e.Value.(Node)
 		e.Value = node
-		return old.(Node)
+		if c.maxBytes > 0 {
+			c.curBytes += Size(node) - Size(old)
+			c.evictBytes()
+		}
+		return old
 	}
 
 	elem := c.ll.PushFront(node)
 	c.dict[keyStr] = elem
 
+	if c.maxBytes > 0 {
+		c.curBytes += Size(node)
+		return c.evictBytes()
+	}
 	if c.ll.Len() > c.maxElementCount {
 		oldest := c.ll.Back()
 		return c.remove(oldest)
@@ -79,6 +111,19 @@ func (c *lruCache) Add(node Node) Node {
 	return nil
 }
 
+// evictBytes removes the least recently used nodes until the cache is within
+// its byte budget. Several nodes may be evicted, only the oldest is returned.
+func (c *lruCache) evictBytes() Node {
+	var oldest Node
+	for c.curBytes > c.maxBytes && c.ll.Len() > 0 {
+		removed := c.remove(c.ll.Back())
+		if oldest == nil {
+			oldest = removed
+		}
+	}
+	return oldest
+}
+
 func (c *lruCache) Get(key []byte) Node {
 	if ele, hit := c.dict[ibytes.UnsafeBytesToStr(key)]; hit {
 		c.ll.MoveToFront(ele)
@@ -106,5 +151,8 @@ func (c *lruCache) Remove(key []byte) Node {
 func (c *lruCache) remove(e *list.Element) Node {
 	removed := c.ll.Remove(e).(Node)
 	delete(c.dict, ibytes.UnsafeBytesToStr(removed.GetKey()))
+	if c.maxBytes > 0 {
+		c.curBytes -= Size(removed)
+	}
 	return removed
 }
@@ -1,6 +1,7 @@
 package cache_test
 
 import (
+	"fmt"
 	"math/rand"
 	"testing"
 
@@ -67,3 +68,82 @@ func BenchmarkRemove(b *testing.B) {
 		_ = cache.Remove(key)
 	}
 }
+
+// BenchmarkMixedScanAndPointReads interleaves random point reads of a working
+// set, which fits in the cache, with a sequential scan of keys that are never
+// read again, as happens when a full iteration or an export runs while blocks
+// are executed. The hit ratio of the point reads is reported.
+func BenchmarkMixedScanAndPointReads(b *testing.B) {
+	const (
+		cacheMax   = 10000
+		workingSet = 8000
+		keySize    = 20
+	)
+
+	policies := map[string]func(int) cache.Cache{
+		"lru": cache.New,
+		"2q":  cache.NewTwoQueue,
+	}
+
+	for name, newCache := range policies {
+		for _, scanPercent := range []int{0, 25, 50} {
+			newCache, scanPercent := newCache, scanPercent
+			b.Run(fmt.Sprintf("%s-scan-%d%%", name, scanPercent), func(b *testing.B) {
+				b.ReportAllocs()
+				r := rand.New(rand.NewSource(498727689))
+				c := newCache(cacheMax)
+				hot := make([][]byte, workingSet)
+				for i := range hot {
+					hot[i] = randBytes(keySize)
+				}
+
+				var scanned, pointReads, hits int
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if r.Intn(100) < scanPercent {
+						b.StopTimer()
+						key := []byte(fmt.Sprintf("scan-%016d", scanned))
+						b.StartTimer()
+						readThrough(c, key)
+						scanned++
+						continue
+					}
+					pointReads++
+					if readThrough(c, hot[r.Intn(workingSet)]) {
+						hits++
+					}
+				}
+				if pointReads > 0 {
+					b.ReportMetric(100*float64(hits)/float64(pointReads), "hit-%")
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkSharedParallelGet measures concurrent reads of a cache split into
+// a varying number of shards.
+func BenchmarkSharedParallelGet(b *testing.B) {
+	const cacheMax = 100000
+
+	for _, shards := range []int{1, 16} {
+		shards := shards
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			c := cache.NewShared(shards, cacheMax, cache.New)
+			keys := make([][]byte, cacheMax/2)
+			for i := range keys {
+				keys[i] = randBytes(32)
+				c.Add(&testNode{key: keys[i]})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					_ = c.Get(keys[r.Intn(len(keys))])
+				}
+			})
+		})
+	}
+}
@@ -20,7 +20,8 @@ const (
 
 // testNode is the node used for testing cache implementation
 type testNode struct {
-	key []byte
+	key  []byte
+	size int // size of the node's encoding, excluding the key
 }
 
 type cacheOp struct {
@@ -39,6 +40,10 @@ func (tn *testNode) GetKey() []byte {
 	return tn.key
 }
 
+func (tn *testNode) EncodedSize() int {
+	return tn.size
+}
+
 const (
 	testKey = "key"
 )
@@ -290,6 +295,74 @@ func Test_Cache_Remove(t *testing.T) {
 	}
 }
 
+func Test_Cache_AddWithBytesLimit(t *testing.T) {
+	node := func(key string, size int) cache.Node {
+		return &testNode{key: []byte(key), size: size}
+	}
+
+	t.Run("evicts oldest once over budget", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Nil(t, c.Add(node("a", 9)))
+		require.Nil(t, c.Add(node("b", 9)))
+		require.Equal(t, 2, c.Len())
+
+		removed := c.Add(node("c", 9))
+		require.Equal(t, node("a", 9), removed)
+		require.Equal(t, 2, c.Len())
+		require.False(t, c.Has([]byte("a")))
+		require.True(t, c.Has([]byte("b")))
+		require.True(t, c.Has([]byte("c")))
+	})
+
+	t.Run("large node evicts several", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Nil(t, c.Add(node("a", 4)))
+		require.Nil(t, c.Add(node("b", 4)))
+		require.Nil(t, c.Add(node("c", 4)))
+
+		removed := c.Add(node("d", 12))
+		require.Equal(t, node("a", 4), removed)
+		require.Equal(t, 2, c.Len())
+		require.True(t, c.Has([]byte("c")))
+		require.True(t, c.Has([]byte("d")))
+	})
+
+	t.Run("get refreshes recency", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Nil(t, c.Add(node("a", 9)))
+		require.Nil(t, c.Add(node("b", 9)))
+		require.NotNil(t, c.Get([]byte("a")))
+
+		require.Equal(t, node("b", 9), c.Add(node("c", 9)))
+		require.True(t, c.Has([]byte("a")))
+	})
+
+	t.Run("replacing a node accounts for its new size", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Nil(t, c.Add(node("a", 4)))
+		require.Nil(t, c.Add(node("b", 4)))
+
+		require.Equal(t, node("b", 4), c.Add(node("b", 16)))
+		require.Equal(t, 1, c.Len())
+		require.Equal(t, node("b", 16), c.Get([]byte("b")))
+	})
+
+	t.Run("node larger than budget is not retained", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Equal(t, node("a", 30), c.Add(node("a", 30)))
+		require.Equal(t, 0, c.Len())
+	})
+
+	t.Run("remove frees budget", func(t *testing.T) {
+		c := cache.NewWithBytesLimit(20)
+		require.Nil(t, c.Add(node("a", 9)))
+		require.Nil(t, c.Add(node("b", 9)))
+		require.NotNil(t, c.Remove([]byte("a")))
+		require.Nil(t, c.Add(node("c", 9)))
+		require.Equal(t, 2, c.Len())
+	})
+}
+
 func validateCacheContentsAfterTest(t *testing.T, tc testcase, cache cache.Cache) {
 	require.Equal(t, len(tc.expectedNodeIndexes), cache.Len())
 	for _, idx := range tc.expectedNodeIndexes {
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+)
+
+// Shared is a concurrency safe cache. It is split into shards, each guarded
+// by its own lock, so that concurrent readers rarely contend with each other.
+//
+// A Shared cache can be used directly, or shared between several trees
+// through namespaces, in which case all of them draw from a single budget.
+type Shared struct {
+	shards     []*shard
+	namespaces uint64 // The last namespace handed out, accessed atomically.
+}
+
+type shard struct {
+	mtx   sync.Mutex
+	cache Cache
+}
+
+var _ Cache = (*Shared)(nil)
+
+// NewShared returns a cache made of shardCount shards created by newShard,
+// e.g. New or NewTwoQueueWithBytesLimit. The budget max, be it a number of
+// nodes or of bytes, is split evenly between the shards. Since each shard
+// evicts on its own, the cache may start evicting before it is full, which
+// is negligible for budgets much larger than the number of shards.
+func NewShared(shardCount, max int, newShard func(max int) Cache) *Shared {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	s := &Shared{shards: make([]*shard, shardCount)}
+	for i := range s.shards {
+		shardMax := max / shardCount
+		if i < max%shardCount {
+			shardMax++
+		}
+		s.shards[i] = &shard{cache: newShard(shardMax)}
+	}
+	return s
+}
+
+// shard returns the shard responsible for key, using the FNV-1a hash of the key.
+func (s *Shared) shard(key []byte) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	h := uint64(14695981039346656037)
+	for _, b := range key {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return s.shards[h%uint64(len(s.shards))]
+}
+
+func (s *Shared) Add(node Node) Node {
+	sh := s.shard(node.GetKey())
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	return sh.cache.Add(node)
+}
+
+func (s *Shared) Get(key []byte) Node {
+	sh := s.shard(key)
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	return sh.cache.Get(key)
+}
+
+func (s *Shared) Has(key []byte) bool {
+	sh := s.shard(key)
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	return sh.cache.Has(key)
+}
+
+func (s *Shared) Remove(key []byte) Node {
+	sh := s.shard(key)
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	return sh.cache.Remove(key)
+}
+
+func (s *Shared) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mtx.Lock()
+		n += sh.cache.Len()
+		sh.mtx.Unlock()
+	}
+	return n
+}
+
+// Namespace returns a view of the cache whose keys never collide with the keys
+// of any other namespace of the same cache. Each call returns a new namespace.
+//
+// The budget is shared between the namespaces, so the Len of a namespace is the
+// number of nodes in the whole Shared cache, across all namespaces.
+func (s *Shared) Namespace() Cache {
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, atomic.AddUint64(&s.namespaces, 1))
+	return &namespace{shared: s, prefix: prefix}
+}
+
+// namespace is a view of a Shared cache, prefixing all keys with a unique prefix.
+//
+// Since the budget is shared, adding a node may evict a node of another
+// namespace, which is then returned by Add. Len returns the number of nodes
+// in the shared cache, across all namespaces.
+type namespace struct {
+	shared *Shared
+	prefix []byte
+}
+
+// namespacedNode wraps a node stored under a namespaced key.
+type namespacedNode struct {
+	Node
+	key []byte
+}
+
+var _ Cache = (*namespace)(nil)
+
+func (n *namespacedNode) GetKey() []byte {
+	return n.key
+}
+
+func (ns *namespace) key(key []byte) []byte {
+	nsKey := make([]byte, len(ns.prefix)+len(key))
+	copy(nsKey, ns.prefix)
+	copy(nsKey[len(ns.prefix):], key)
+	return nsKey
+}
+
+// unwrap returns the node wrapped by a namespaced node. A node added to the Shared cache
+// directly, whose key happens to start with a namespace prefix, is treated as a miss.
+func unwrap(node Node) Node {
+	nsNode, ok := node.(*namespacedNode)
+	if !ok {
+		return nil
+	}
+	return nsNode.Node
+}
+
+func (ns *namespace) Add(node Node) Node {
+	return unwrap(ns.shared.Add(&namespacedNode{Node: node, key: ns.key(node.GetKey())}))
+}
+
+func (ns *namespace) Get(key []byte) Node {
+	return unwrap(ns.shared.Get(ns.key(key)))
+}
+
+// Has is Get, so that a node added to the Shared cache directly is a miss, as for Get.
+func (ns *namespace) Has(key []byte) bool {
+	return ns.Get(key) != nil
+}
+
+func (ns *namespace) Remove(key []byte) Node {
+	return unwrap(ns.shared.Remove(ns.key(key)))
+}
+
+// Len returns the number of nodes in the Shared cache, across all namespaces.
+func (ns *namespace) Len() int {
+	return ns.shared.Len()
+}
@@ -0,0 +1,100 @@
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/iavl/cache"
+)
+
+func Test_Shared_BudgetSplitBetweenShards(t *testing.T) {
+	c := cache.NewShared(3, 10, cache.New)
+	for i := 0; i < 100; i++ {
+		c.Add(&testNode{key: []byte(fmt.Sprintf("%s%d", testKey, i))})
+	}
+	require.LessOrEqual(t, c.Len(), 10)
+	require.Positive(t, c.Len())
+}
+
+func Test_Shared_AddGetRemove(t *testing.T) {
+	c := cache.NewShared(4, 100, cache.New)
+	for _, node := range testNodes {
+		require.Nil(t, c.Add(node))
+	}
+	require.Equal(t, len(testNodes), c.Len())
+	for _, node := range testNodes {
+		require.True(t, c.Has(node.GetKey()))
+		require.Equal(t, node, c.Get(node.GetKey()))
+	}
+	require.Equal(t, testNodes[0], c.Remove(testNodes[0].GetKey()))
+	require.Nil(t, c.Get(testNodes[0].GetKey()))
+	require.Equal(t, len(testNodes)-1, c.Len())
+}
+
+func Test_Shared_Namespaces(t *testing.T) {
+	c := cache.NewShared(4, 100, cache.New)
+	ns1, ns2 := c.Namespace(), c.Namespace()
+
+	key := []byte("key")
+	node1, node2 := &testNode{key: key}, &testNode{key: key}
+	require.Nil(t, ns1.Add(node1))
+	require.False(t, ns2.Has(key))
+	require.Nil(t, ns2.Get(key))
+	require.False(t, c.Has(key))
+
+	require.Nil(t, ns2.Add(node2))
+	require.Same(t, node1, ns1.Get(key))
+	require.Same(t, node2, ns2.Get(key))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, 2, ns1.Len())
+
+	require.Same(t, node1, ns1.Remove(key))
+	require.False(t, ns1.Has(key))
+	require.True(t, ns2.Has(key))
+}
+
+func Test_Shared_NamespaceCollidingWithDirectKey(t *testing.T) {
+	c := cache.NewShared(4, 100, cache.New)
+	ns := c.Namespace()
+
+	// The first namespace is prefixed with 1, as an 8-byte big-endian integer.
+	key := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, "key"...)
+	require.Nil(t, c.Add(&testNode{key: key}))
+	require.Nil(t, ns.Get([]byte("key")))
+	require.False(t, ns.Has([]byte("key")))
+	require.Nil(t, ns.Remove([]byte("key")))
+}
+
+func Test_Shared_NamespacesShareBudget(t *testing.T) {
+	c := cache.NewShared(1, 2, cache.New)
+	ns1, ns2 := c.Namespace(), c.Namespace()
+	require.Nil(t, ns1.Add(testNodes[0]))
+	require.Nil(t, ns1.Add(testNodes[1]))
+
+	// The oldest node of the other namespace is evicted.
+	require.Equal(t, testNodes[0], ns2.Add(testNodes[2]))
+	require.False(t, ns1.Has(testNodes[0].GetKey()))
+	require.True(t, ns1.Has(testNodes[1].GetKey()))
+	require.True(t, ns2.Has(testNodes[2].GetKey()))
+}
+
+func Test_Shared_Concurrent(t *testing.T) {
+	c := cache.NewShared(8, 1000, cache.NewTwoQueue)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		ns := c.Namespace()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				readThrough(ns, []byte(fmt.Sprintf("%s%d", testKey, i%300)))
+				ns.Remove([]byte(fmt.Sprintf("%s%d", testKey, i%7)))
+			}
+		}()
+	}
+	wg.Wait()
+	require.LessOrEqual(t, c.Len(), 1000)
+}
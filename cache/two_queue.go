@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"container/list"
+
+	ibytes "github.com/cosmos/iavl/internal/bytes"
+)
+
+// twoQueueCache is a scan resistant cache implementing the 2Q policy
+// described by Johnson and Shasha.
+//
+// Nodes seen for the first time are admitted to the recent queue, a
+// FIFO that holds about a quarter of the cache. When they fall out of
+// it, only their keys are remembered in the ghost queue. A node that is
+// added again while its key is still in the ghost queue has proven to
+// be reused and is admitted to the frequent queue, an LRU holding the
+// rest of the cache.
+//
+// A full iteration or an export touches every node once, so it only
+// churns the recent queue and leaves the working set in the frequent
+// queue untouched, whereas it would flush a plain LRU cache.
+type twoQueueCache struct {
+	dict   map[string]*list.Element // Resident nodes, in either the recent or the frequent queue.
+	ghosts map[string]*list.Element // Keys of nodes recently evicted from the recent queue.
+
+	recent   *list.List // FIFO queue of nodes seen once.
+	frequent *list.List // LRU queue of nodes seen more than once.
+	ghost    *list.List // FIFO queue of ghost keys.
+
+	recentWeight, frequentWeight, ghostWeight int
+	maxWeight, maxRecent, maxGhost            int
+
+	weigh func(Node) int // Either 1 per node or its size in bytes.
+}
+
+type twoQueueEntry struct {
+	node     Node
+	frequent bool
+}
+
+type ghostEntry struct {
+	key    string
+	weight int
+}
+
+var _ Cache = (*twoQueueCache)(nil)
+
+// NewTwoQueue returns a 2Q cache holding at most maxElementCount nodes.
+func NewTwoQueue(maxElementCount int) Cache {
+	return newTwoQueue(maxElementCount, func(Node) int { return 1 })
+}
+
+// NewTwoQueueWithBytesLimit returns a 2Q cache holding nodes whose total
+// size, as reported by Size, does not exceed maxBytes.
+func NewTwoQueueWithBytesLimit(maxBytes int) Cache {
+	return newTwoQueue(maxBytes, Size)
+}
+
+func newTwoQueue(maxWeight int, weigh func(Node) int) *twoQueueCache {
+	return &twoQueueCache{
+		dict:      make(map[string]*list.Element),
+		ghosts:    make(map[string]*list.Element),
+		recent:    list.New(),
+		frequent:  list.New(),
+		ghost:     list.New(),
+		maxWeight: maxWeight,
+		maxRecent: maxWeight / 4,
+		maxGhost:  maxWeight / 2,
+		weigh:     weigh,
+	}
+}
+
+func (c *twoQueueCache) Add(node Node) Node {
+	keyStr := ibytes.UnsafeBytesToStr(node.GetKey())
+	if e, exists := c.dict[keyStr]; exists {
+		entry := e.Value.(*twoQueueEntry)
+		old := entry.node
+		entry.node = node
+		if entry.frequent {
+			c.frequent.MoveToFront(e)
+			c.frequentWeight += c.weigh(node) - c.weigh(old)
+		} else {
+			c.recentWeight += c.weigh(node) - c.weigh(old)
+		}
+		c.evict()
+		return old
+	}
+
+	entry := &twoQueueEntry{node: node}
+	if g, exists := c.ghosts[keyStr]; exists {
+		c.removeGhost(g)
+		entry.frequent = true
+		c.dict[keyStr] = c.frequent.PushFront(entry)
+		c.frequentWeight += c.weigh(node)
+	} else {
+		c.dict[keyStr] = c.recent.PushFront(entry)
+		c.recentWeight += c.weigh(node)
+	}
+	return c.evict()
+}
+
+func (c *twoQueueCache) Get(key []byte) Node {
+	if e, hit := c.dict[ibytes.UnsafeBytesToStr(key)]; hit {
+		entry := e.Value.(*twoQueueEntry)
+		// Hits in the recent queue are not promoted, a node has to be
+		// evicted and added again to be considered frequently used.
+		if entry.frequent {
+			c.frequent.MoveToFront(e)
+		}
+		return entry.node
+	}
+	return nil
+}
+
+func (c *twoQueueCache) Has(key []byte) bool {
+	_, exists := c.dict[ibytes.UnsafeBytesToStr(key)]
+	return exists
+}
+
+func (c *twoQueueCache) Len() int {
+	return c.recent.Len() + c.frequent.Len()
+}
+
+func (c *twoQueueCache) Remove(key []byte) Node {
+	if e, exists := c.dict[ibytes.UnsafeBytesToStr(key)]; exists {
+		return c.remove(e)
+	}
+	return nil
+}
+
+// evict removes nodes until the cache is within its budget, taking them from
+// the recent queue while it exceeds its share. Evicted recent nodes are
+// remembered as ghosts. Several nodes may be evicted, only the oldest is returned.
+func (c *twoQueueCache) evict() Node {
+	var oldest Node
+	for c.recentWeight+c.frequentWeight > c.maxWeight && c.Len() > 0 {
+		var removed Node
+		if c.recent.Len() > 0 && (c.recentWeight > c.maxRecent || c.frequent.Len() == 0) {
+			removed = c.remove(c.recent.Back())
+			c.addGhost(removed)
+		} else {
+			removed = c.remove(c.frequent.Back())
+		}
+		if oldest == nil {
+			oldest = removed
+		}
+	}
+	return oldest
+}
+
+func (c *twoQueueCache) remove(e *list.Element) Node {
+	entry := e.Value.(*twoQueueEntry)
+	if entry.frequent {
+		c.frequent.Remove(e)
+		c.frequentWeight -= c.weigh(entry.node)
+	} else {
+		c.recent.Remove(e)
+		c.recentWeight -= c.weigh(entry.node)
+	}
+	delete(c.dict, ibytes.UnsafeBytesToStr(entry.node.GetKey()))
+	return entry.node
+}
+
+func (c *twoQueueCache) addGhost(node Node) {
+	g := &ghostEntry{key: string(node.GetKey()), weight: c.weigh(node)}
+	if g.weight > c.maxGhost {
+		return
+	}
+	c.ghosts[g.key] = c.ghost.PushFront(g)
+	c.ghostWeight += g.weight
+	for c.ghostWeight > c.maxGhost {
+		c.removeGhost(c.ghost.Back())
+	}
+}
+
+func (c *twoQueueCache) removeGhost(e *list.Element) {
+	g := c.ghost.Remove(e).(*ghostEntry)
+	delete(c.ghosts, g.key)
+	c.ghostWeight -= g.weight
+}
@@ -0,0 +1,122 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/iavl/cache"
+)
+
+// readThrough reads key from the cache, adding it on a miss like the nodeDB does.
+// It returns true on a hit.
+func readThrough(c cache.Cache, key []byte) bool {
+	if c.Get(key) != nil {
+		return true
+	}
+	c.Add(&testNode{key: key})
+	return false
+}
+
+func Test_TwoQueue_AddGetRemove(t *testing.T) {
+	c := cache.NewTwoQueue(2)
+	require.Nil(t, c.Add(testNodes[0]))
+	require.Nil(t, c.Add(testNodes[1]))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, testNodes[0], c.Get(testNodes[0].GetKey()))
+
+	// With an empty frequent queue the oldest node is evicted, even if it was read.
+	require.Equal(t, testNodes[0], c.Add(testNodes[2]))
+	require.Equal(t, 2, c.Len())
+	require.False(t, c.Has(testNodes[0].GetKey()))
+	require.True(t, c.Has(testNodes[1].GetKey()))
+	require.True(t, c.Has(testNodes[2].GetKey()))
+
+	require.Equal(t, testNodes[1], c.Remove(testNodes[1].GetKey()))
+	require.Nil(t, c.Remove(testNodes[1].GetKey()))
+	require.Equal(t, 1, c.Len())
+
+	replacement := &testNode{key: testNodes[2].GetKey()}
+	require.Equal(t, testNodes[2], c.Add(replacement))
+	require.Equal(t, 1, c.Len())
+	require.Same(t, replacement, c.Get(testNodes[2].GetKey()))
+}
+
+func Test_TwoQueue_ZeroMax(t *testing.T) {
+	c := cache.NewTwoQueue(0)
+	require.Equal(t, testNodes[0], c.Add(testNodes[0]))
+	require.Equal(t, 0, c.Len())
+	require.Nil(t, c.Get(testNodes[0].GetKey()))
+}
+
+func Test_TwoQueue_BytesLimit(t *testing.T) {
+	c := cache.NewTwoQueueWithBytesLimit(20)
+	require.Nil(t, c.Add(&testNode{key: []byte("a"), size: 4}))
+	require.Nil(t, c.Add(&testNode{key: []byte("b"), size: 4}))
+	require.Nil(t, c.Add(&testNode{key: []byte("c"), size: 4}))
+
+	removed := c.Add(&testNode{key: []byte("d"), size: 12})
+	require.Equal(t, []byte("a"), removed.GetKey())
+	require.Equal(t, 2, c.Len())
+	require.True(t, c.Has([]byte("c")))
+	require.True(t, c.Has([]byte("d")))
+
+	big := &testNode{key: []byte("e"), size: 30}
+	require.NotNil(t, c.Add(big))
+	require.False(t, c.Has([]byte("e")))
+}
+
+func Test_TwoQueue_ScanResistance(t *testing.T) {
+	const cacheMax, hotKeys = 100, 50
+	key := func(prefix string, i int) []byte {
+		return []byte(fmt.Sprintf("%s%d", prefix, i))
+	}
+
+	testcases := map[string]struct {
+		cache       cache.Cache
+		hotRetained bool
+	}{
+		"lru": {cache.New(cacheMax), false},
+		"2q":  {cache.NewTwoQueue(cacheMax), true},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			// Interleave reads of a working set with a few other reads, until
+			// the working set is established.
+			other := 0
+			for round := 0; round < 20; round++ {
+				for i := 0; i < hotKeys; i++ {
+					readThrough(tc.cache, key("hot", i))
+				}
+				for i := 0; i < 40; i++ {
+					readThrough(tc.cache, key("other", other))
+					other++
+				}
+			}
+			for i := 0; i < hotKeys; i++ {
+				require.True(t, readThrough(tc.cache, key("hot", i)))
+			}
+
+			// A full scan touches every key exactly once.
+			for i := 0; i < 10*cacheMax; i++ {
+				readThrough(tc.cache, key("scan", i))
+			}
+			require.LessOrEqual(t, tc.cache.Len(), cacheMax)
+
+			retained := 0
+			for i := 0; i < hotKeys; i++ {
+				if tc.cache.Has(key("hot", i)) {
+					retained++
+				}
+			}
+			if tc.hotRetained {
+				require.Equal(t, hotKeys, retained)
+			} else {
+				require.Zero(t, retained)
+			}
+		})
+	}
+}
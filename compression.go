@@ -0,0 +1,117 @@
+package iavl
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the codec used to compress node and fast node encodings before they
+// are written to the database. Compression only affects the stored representation, node hashes
+// and proofs are always computed over the uncompressed encoding.
+type Compression byte
+
+const (
+	// NoCompression stores encodings as is. This is the default.
+	NoCompression Compression = iota
+	// SnappyCompression compresses encodings with snappy.
+	SnappyCompression
+	// ZstdCompression compresses encodings with zstd.
+	ZstdCompression
+)
+
+// Compressed records are prefixed by a header byte identifying the codec. Uncompressed records
+// start with the zigzag varint of a non-negative height (nodes) or version (fast nodes), whose
+// low bit is always clear, so a header byte with the low bit set can never be mistaken for an
+// uncompressed record. This allows a store to contain a mix of both during a migration.
+const (
+	snappyHeader byte = 0x01
+	zstdHeader   byte = 0x03
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// String implements fmt.Stringer.
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case SnappyCompression:
+		return "snappy"
+	case ZstdCompression:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(c))
+	}
+}
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	return zstdErr
+}
+
+// compressBytes compresses bz with the given codec and prepends the codec header. If the
+// compressed form is not smaller than bz, bz is returned unchanged so that small records, which
+// are the majority of inner nodes, do not pay for the header.
+func compressBytes(c Compression, bz []byte) ([]byte, error) {
+	var out []byte
+	switch c {
+	case NoCompression:
+		return bz, nil
+	case SnappyCompression:
+		out = make([]byte, 1+snappy.MaxEncodedLen(len(bz)))
+		out[0] = snappyHeader
+		out = out[:1+len(snappy.Encode(out[1:], bz))]
+	case ZstdCompression:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		out = zstdEncoder.EncodeAll(bz, []byte{zstdHeader})
+	default:
+		return nil, fmt.Errorf("unknown compression %d", byte(c))
+	}
+	if len(out) >= len(bz) {
+		return bz, nil
+	}
+	return out, nil
+}
+
+// decompressBytes returns the uncompressed encoding of a stored record, regardless of the
+// compression the store is currently configured with.
+func decompressBytes(bz []byte) ([]byte, error) {
+	if len(bz) == 0 || bz[0]&1 == 0 {
+		return bz, nil
+	}
+	switch bz[0] {
+	case snappyHeader:
+		out, err := snappy.Decode(nil, bz[1:])
+		if err != nil {
+			return nil, fmt.Errorf("decompressing snappy record, %w", err)
+		}
+		return out, nil
+	case zstdHeader:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		out, err := zstdDecoder.DecodeAll(bz[1:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing zstd record, %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown compression header %#x", bz[0])
+	}
+}
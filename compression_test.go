@@ -0,0 +1,163 @@
+package iavl
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/iavl/fastnode"
+)
+
+func TestCompression_RoundTrip(t *testing.T) {
+	value := bytes.Repeat([]byte("compressible"), 64)
+	node := NewNode([]byte("key"), value, 7)
+	var buf bytes.Buffer
+	require.NoError(t, node.writeBytes(&buf))
+	encoded := buf.Bytes()
+
+	testcases := map[string]struct {
+		compression Compression
+		header      byte
+	}{
+		"none":   {NoCompression, 0},
+		"snappy": {SnappyCompression, snappyHeader},
+		"zstd":   {ZstdCompression, zstdHeader},
+	}
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			bz, err := compressBytes(tc.compression, encoded)
+			require.NoError(t, err)
+			if tc.compression == NoCompression {
+				require.Equal(t, encoded, bz)
+			} else {
+				require.Equal(t, tc.header, bz[0])
+				require.Less(t, len(bz), len(encoded))
+			}
+
+			decoded, err := decompressBytes(bz)
+			require.NoError(t, err)
+			require.Equal(t, encoded, decoded)
+		})
+	}
+}
+
+func TestCompression_IncompressibleStoredRaw(t *testing.T) {
+	node := NewNode([]byte("k"), []byte("v"), 1)
+	var buf bytes.Buffer
+	require.NoError(t, node.writeBytes(&buf))
+
+	for _, c := range []Compression{SnappyCompression, ZstdCompression} {
+		bz, err := compressBytes(c, buf.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, buf.Bytes(), bz)
+	}
+}
+
+func TestCompression_UncompressedHeaderBitClear(t *testing.T) {
+	// The first byte of uncompressed records must never collide with a compression header.
+	for _, height := range []int8{0, 1, 63, 64, 127} {
+		node := &Node{key: []byte{1}, subtreeHeight: height, size: 1, version: 1, value: []byte{1}}
+		if height > 0 {
+			node.value = nil
+			node.leftHash = make([]byte, hashSize)
+			node.rightHash = make([]byte, hashSize)
+		}
+		var buf bytes.Buffer
+		require.NoError(t, node.writeBytes(&buf))
+		require.Zero(t, buf.Bytes()[0]&1, "height %d", height)
+	}
+	for _, version := range []int64{0, 1, 63, 64, 1 << 40} {
+		var buf bytes.Buffer
+		require.NoError(t, fastnode.NewNode([]byte{1}, []byte{1}, version).WriteBytes(&buf))
+		require.Zero(t, buf.Bytes()[0]&1, "version %d", version)
+	}
+}
+
+func TestCompression_UnknownHeader(t *testing.T) {
+	_, err := decompressBytes([]byte{0x7f, 1, 2, 3})
+	require.Error(t, err)
+
+	_, err = compressBytes(Compression(42), []byte{1, 2, 3})
+	require.Error(t, err)
+}
+
+func TestCompression_TreeHashUnchanged(t *testing.T) {
+	build := func(c Compression) []byte {
+		r := rand.New(rand.NewSource(49872768940))
+		opts := DefaultOptions()
+		opts.Compression = c
+		tree, err := NewMutableTreeWithOpts(db.NewMemDB(), 0, &opts, false)
+		require.NoError(t, err)
+		for i := 0; i < 200; i++ {
+			key, value := make([]byte, 8), make([]byte, 4)
+			r.Read(key)
+			r.Read(value)
+			_, err = tree.Set(key, bytes.Repeat(value, 20))
+			require.NoError(t, err)
+		}
+		hash, _, err := tree.SaveVersion()
+		require.NoError(t, err)
+		return hash
+	}
+
+	expected := build(NoCompression)
+	require.Equal(t, expected, build(SnappyCompression))
+	require.Equal(t, expected, build(ZstdCompression))
+}
+
+func TestCompression_MixedRecords(t *testing.T) {
+	memDB := db.NewMemDB()
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 100)
+	}
+
+	// Each version is written with a different codec, as would happen during a migration.
+	codecs := []Compression{NoCompression, SnappyCompression, ZstdCompression, NoCompression}
+	for v, c := range codecs {
+		opts := DefaultOptions()
+		opts.Compression = c
+		tree, err := NewMutableTreeWithOpts(memDB, 0, &opts, false)
+		require.NoError(t, err)
+		_, err = tree.Load()
+		require.NoError(t, err)
+		for i := 0; i < 50; i++ {
+			_, err = tree.Set([]byte{byte(v), byte(i)}, value(i))
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	tree, err := NewMutableTreeWithOpts(memDB, 0, nil, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	require.EqualValues(t, 200, tree.Size())
+
+	for v := range codecs {
+		for i := 0; i < 50; i++ {
+			key := []byte{byte(v), byte(i)}
+			// fast node lookup
+			val, err := tree.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, value(i), val)
+			// tree lookup
+			_, val, err = tree.GetWithIndex(key)
+			require.NoError(t, err)
+			require.Equal(t, value(i), val)
+		}
+	}
+
+	count := 0
+	_, err = tree.Iterate(func(key, val []byte) bool {
+		require.Equal(t, value(int(key[1])), val)
+		count++
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200, count)
+}
@@ -1,8 +1,10 @@
 package iavl
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 )
 
 // exportBufferSize is the number of nodes to buffer in the exporter. It improves throughput by
@@ -13,6 +15,10 @@ const exportBufferSize = 32
 // ErrorExportDone is returned by Exporter.Next() when all items have been exported.
 var ErrorExportDone = errors.New("export is complete")
 
+// ErrResumeMismatch is returned when resuming an export or an import of another version or root
+// hash than the one being resumed.
+var ErrResumeMismatch = errors.New("cannot resume from another version or root hash")
+
 // ExportNode contains exported node data.
 type ExportNode struct {
 	Key     []byte
@@ -21,60 +27,151 @@ type ExportNode struct {
 	Height  int8
 }
 
+// ExportCursor identifies a position in the export of a tree, from which the export can be
+// resumed with ImmutableTree.ExportFromCursor. It is returned by Exporter.Cursor, and by
+// Importer.Cursor for a resumable import.
+type ExportCursor struct {
+	Version  int64  // The version of the exported tree.
+	RootHash []byte // The root hash of the exported tree.
+	Position int64  // The number of nodes exported before the position.
+}
+
 // Exporter exports nodes from an ImmutableTree. It is created by ImmutableTree.Export().
 //
 // Exported nodes can be imported into an empty tree with MutableTree.Import(). Nodes are exported
 // depth-first post-order (LRN), this order must be preserved when importing in order to recreate
 // the same tree structure.
 type Exporter struct {
-	tree   *ImmutableTree
-	ch     chan *ExportNode
-	cancel context.CancelFunc
+	tree     *ImmutableTree
+	ch       chan *ExportNode
+	cancel   context.CancelFunc
+	parent   context.Context
+	err      error // Set before ch is closed, if the export was stopped by the parent context or failed.
+	start    int64 // The position the export started from.
+	position int64 // The position after the nodes returned by Next.
+	version  int64
+	rootHash []byte
 }
 
-// NewExporter creates a new Exporter. Callers must call Close() when done.
-func newExporter(tree *ImmutableTree) *Exporter {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewExporter creates a new Exporter, starting after the given number of nodes. Callers must call
+// Close() when done.
+func newExporter(parent context.Context, tree *ImmutableTree, start int64) *Exporter {
+	ctx, cancel := context.WithCancel(parent)
 	exporter := &Exporter{
-		tree:   tree,
-		ch:     make(chan *ExportNode, exportBufferSize),
-		cancel: cancel,
+		tree:     tree,
+		ch:       make(chan *ExportNode, exportBufferSize),
+		cancel:   cancel,
+		parent:   parent,
+		start:    start,
+		position: start,
+		version:  tree.version,
 	}
 
 	tree.ndb.incrVersionReaders(tree.version)
+	exporter.rootHash, exporter.err = tree.Hash()
+	if exporter.err != nil {
+		close(exporter.ch)
+		return exporter
+	}
 	go exporter.export(ctx)
 
 	return exporter
 }
 
+// ExportFromCursor is ExportWithContext, resuming the export of the tree at cursor. It fails with
+// ErrResumeMismatch if the tree is of another version or root hash than the cursor.
+func (t *ImmutableTree) ExportFromCursor(ctx context.Context, cursor *ExportCursor) (*Exporter, error) {
+	if cursor == nil {
+		return nil, errors.New("cursor cannot be nil")
+	}
+	rootHash, err := t.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if cursor.Version != t.version || !bytes.Equal(cursor.RootHash, rootHash) {
+		return nil, fmt.Errorf("%w: cursor of version %d with root hash %X, tree of version %d with root hash %X",
+			ErrResumeMismatch, cursor.Version, cursor.RootHash, t.version, rootHash)
+	}
+	var nodes int64
+	if t.root != nil {
+		nodes = 2*t.root.size - 1
+	}
+	if cursor.Position < 0 || cursor.Position > nodes {
+		return nil, fmt.Errorf("cursor position %d out of range [0, %d]", cursor.Position, nodes)
+	}
+	return newExporter(ctx, t, cursor.Position), nil
+}
+
 // export exports nodes
 func (e *Exporter) export(ctx context.Context) {
-	e.tree.root.traversePost(e.tree, true, func(node *Node) bool {
-		exportNode := &ExportNode{
-			Key:     node.key,
-			Value:   node.value,
-			Version: node.version,
-			Height:  node.subtreeHeight,
+	if e.tree.root != nil {
+		skip := e.start
+		exported, err := e.exportSubtree(ctx, e.tree.root, &skip)
+		switch {
+		case err != nil:
+			e.err = err
+		case !exported:
+			e.err = e.parent.Err()
 		}
+	}
+	close(e.ch)
+}
 
-		select {
-		case e.ch <- exportNode:
-			return false
-		case <-ctx.Done():
-			return true
+// exportSubtree exports the nodes of the subtree of node in post-order, skipping the first skip
+// of them. It returns false if the export was stopped by ctx.
+func (e *Exporter) exportSubtree(ctx context.Context, node *Node, skip *int64) (bool, error) {
+	// A subtree of n leaves has 2n-1 nodes, which are skipped at once.
+	if nodes := 2*node.size - 1; *skip >= nodes {
+		*skip -= nodes
+		return true, nil
+	}
+	if !node.isLeaf() {
+		leftNode, err := node.getLeftNode(e.tree)
+		if err != nil {
+			return false, err
 		}
-	})
-	close(e.ch)
+		if exported, err := e.exportSubtree(ctx, leftNode, skip); !exported || err != nil {
+			return exported, err
+		}
+		rightNode, err := node.getRightNode(e.tree)
+		if err != nil {
+			return false, err
+		}
+		if exported, err := e.exportSubtree(ctx, rightNode, skip); !exported || err != nil {
+			return exported, err
+		}
+	}
+	select {
+	case e.ch <- newExportNode(node):
+		return true, nil
+	case <-ctx.Done():
+		return false, nil
+	}
 }
 
-// Next fetches the next exported node, or returns ExportDone when done.
+// Next fetches the next exported node, or returns ExportDone when done. If the export was
+// stopped by the cancellation of its context, the context error is returned instead.
 func (e *Exporter) Next() (*ExportNode, error) {
 	if exportNode, ok := <-e.ch; ok {
+		e.position++
 		return exportNode, nil
 	}
+	if e.err != nil {
+		return nil, e.err
+	}
 	return nil, ErrorExportDone
 }
 
+// Cursor returns the position of the export after the nodes returned by Next, from which the
+// export can be resumed.
+func (e *Exporter) Cursor() *ExportCursor {
+	return &ExportCursor{
+		Version:  e.version,
+		RootHash: e.rootHash,
+		Position: e.position,
+	}
+}
+
 // Close closes the exporter. It is safe to call multiple times.
 func (e *Exporter) Close() {
 	e.cancel()
@@ -85,3 +182,146 @@ func (e *Exporter) Close() {
 	}
 	e.tree = nil
 }
+
+// ExportChunk is a chunk of the export of a tree, see ImmutableTree.ExportChunks. It holds the
+// nodes of a subtree, followed by the ancestors of the subtree which come right after it in the
+// depth-first post-order (LRN) of the export. Concatenating the Subtree and Ancestors of all the
+// chunks in the order of their Index yields the nodes returned by Exporter.
+//
+// Chunks can be imported in any order with Importer.AddChunk.
+type ExportChunk struct {
+	Version   int64         // The version of the exported tree.
+	RootHash  []byte        // The root hash of the exported tree.
+	Index     int           // The position of the chunk in the export.
+	Count     int           // The number of chunks of the export.
+	Subtree   []*ExportNode // The nodes of the subtree of the chunk, in post-order.
+	Ancestors []*ExportNode // The ancestors of the subtree following it, from the bottom up.
+}
+
+// ChunkedExport splits the export of a tree into chunks that can be exported concurrently. It is
+// created by ImmutableTree.ExportChunks.
+type ChunkedExport struct {
+	tree     *ImmutableTree
+	rootHash []byte
+	chunks   []chunkPlan
+}
+
+// chunkPlan is the subtree root and the ancestors of a chunk.
+type chunkPlan struct {
+	root      *Node
+	ancestors []*Node
+}
+
+/*
+ExportChunks splits the export of the tree at depth: each subtree whose root is at depth, and each
+leaf above it, is a chunk. The number of chunks is at most 2^depth, and depth 0 exports the tree
+as a single chunk. An empty tree has no chunks. Callers must call Close() when done.
+
+The chunks are exported by ChunkedExport.Chunk, which can be called concurrently, as long as the
+tree is not modified meanwhile.
+*/
+func (t *ImmutableTree) ExportChunks(depth int) (*ChunkedExport, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("invalid export depth %d", depth)
+	}
+	// The chunks are exported concurrently, so they are exported from a copy of the tree which
+	// does not record a witness nor meter its reads.
+	rootHash, err := t.Hash()
+	if err != nil {
+		return nil, err
+	}
+	e := &ChunkedExport{tree: t.clone(), rootHash: rootHash}
+	e.tree.ndb.incrVersionReaders(e.tree.version)
+	if t.root != nil {
+		if err := e.plan(t.root, depth); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// plan appends the chunks of the subtree of node, which is depth above the chunk roots.
+func (e *ChunkedExport) plan(node *Node, depth int) error {
+	if depth == 0 || node.isLeaf() {
+		e.chunks = append(e.chunks, chunkPlan{root: node})
+		return nil
+	}
+	leftNode, err := node.getLeftNode(e.tree)
+	if err != nil {
+		return err
+	}
+	if err := e.plan(leftNode, depth-1); err != nil {
+		return err
+	}
+	rightNode, err := node.getRightNode(e.tree)
+	if err != nil {
+		return err
+	}
+	if err := e.plan(rightNode, depth-1); err != nil {
+		return err
+	}
+	last := &e.chunks[len(e.chunks)-1]
+	last.ancestors = append(last.ancestors, node)
+	return nil
+}
+
+// Count returns the number of chunks.
+func (e *ChunkedExport) Count() int {
+	return len(e.chunks)
+}
+
+// Chunk exports the chunk at index. If ctx is cancelled, the export of the chunk stops and
+// ctx.Err() is returned.
+func (e *ChunkedExport) Chunk(ctx context.Context, index int) (*ExportChunk, error) {
+	if e.tree == nil {
+		return nil, errors.New("export is closed")
+	}
+	if index < 0 || index >= len(e.chunks) {
+		return nil, fmt.Errorf("chunk index %d out of range [0, %d)", index, len(e.chunks))
+	}
+	plan := e.chunks[index]
+	chunk := &ExportChunk{
+		Version:   e.tree.version,
+		RootHash:  e.rootHash,
+		Index:     index,
+		Count:     len(e.chunks),
+		Ancestors: make([]*ExportNode, len(plan.ancestors)),
+	}
+	traversal := plan.root.newTraversal(e.tree, nil, nil, true, false, true)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		node, err := traversal.next()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			break
+		}
+		chunk.Subtree = append(chunk.Subtree, newExportNode(node))
+	}
+	for i, node := range plan.ancestors {
+		chunk.Ancestors[i] = newExportNode(node)
+	}
+	return chunk, nil
+}
+
+// Close closes the export. It is safe to call multiple times.
+func (e *ChunkedExport) Close() {
+	if e.tree != nil {
+		e.tree.ndb.decrVersionReaders(e.tree.version)
+	}
+	e.tree = nil
+}
+
+// newExportNode returns the ExportNode of node.
+func newExportNode(node *Node) *ExportNode {
+	return &ExportNode{
+		Key:     node.key,
+		Value:   node.value,
+		Version: node.version,
+		Height:  node.subtreeHeight,
+	}
+}
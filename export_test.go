@@ -1,8 +1,10 @@
 package iavl
 
 import (
+	"context"
 	"math"
 	"math/rand"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -303,3 +305,158 @@ func BenchmarkExport(b *testing.B) {
 		exporter.Close()
 	}
 }
+
+func TestExporter_Context(t *testing.T) {
+	tree := setupExportTreeRandom(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter := tree.ExportWithContext(ctx)
+	defer exporter.Close()
+
+	_, err := exporter.Next()
+	require.NoError(t, err)
+	cancel()
+	for err == nil {
+		_, err = exporter.Next()
+	}
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// exportNodes returns the nodes exported by tree.Export.
+func exportNodes(t *testing.T, tree *ImmutableTree) []*ExportNode {
+	exporter := tree.Export()
+	defer exporter.Close()
+	var nodes []*ExportNode
+	for {
+		node, err := exporter.Next()
+		if err == ErrorExportDone {
+			return nodes
+		}
+		require.NoError(t, err)
+		nodes = append(nodes, node)
+	}
+}
+
+func TestExporter_Chunks(t *testing.T) {
+	testcases := map[string]*ImmutableTree{
+		"empty tree": NewImmutableTree(db.NewMemDB(), 0, false),
+		"basic tree": setupExportTreeBasic(t),
+	}
+	if !testing.Short() {
+		testcases["sized tree"] = setupExportTreeSized(t, 4096)
+	}
+
+	for desc, tree := range testcases {
+		tree := tree
+		t.Run(desc, func(t *testing.T) {
+			expect := exportNodes(t, tree)
+			for _, depth := range []int{0, 1, 2, 5, 20} {
+				export, err := tree.ExportChunks(depth)
+				require.NoError(t, err)
+				if tree.root == nil {
+					require.Zero(t, export.Count())
+				} else {
+					require.Positive(t, export.Count())
+					require.LessOrEqual(t, export.Count(), 1<<depth)
+				}
+
+				// The chunks are exported concurrently, and their nodes concatenated in order are
+				// the nodes of the sequential export.
+				chunks := make([]*ExportChunk, export.Count())
+				var wg sync.WaitGroup
+				for i := range chunks {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						chunk, err := export.Chunk(context.Background(), i)
+						assert.NoError(t, err)
+						chunks[i] = chunk
+					}(i)
+				}
+				wg.Wait()
+				export.Close()
+
+				var actual []*ExportNode
+				for i, chunk := range chunks {
+					require.Equal(t, i, chunk.Index)
+					require.Equal(t, len(chunks), chunk.Count)
+					require.NotEmpty(t, chunk.Subtree)
+					actual = append(actual, chunk.Subtree...)
+					actual = append(actual, chunk.Ancestors...)
+				}
+				require.Equal(t, expect, actual, "depth %d", depth)
+			}
+		})
+	}
+}
+
+func TestExporter_Chunks_Errors(t *testing.T) {
+	tree := setupExportTreeBasic(t)
+	_, err := tree.ExportChunks(-1)
+	require.Error(t, err)
+
+	export, err := tree.ExportChunks(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, export.Count())
+	_, err = export.Chunk(context.Background(), 2)
+	require.Error(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = export.Chunk(ctx, 0)
+	require.ErrorIs(t, err, context.Canceled)
+
+	export.Close()
+	export.Close()
+	_, err = export.Chunk(context.Background(), 0)
+	require.Error(t, err)
+}
+
+func TestExporter_Cursor(t *testing.T) {
+	tree := setupExportTreeSized(t, 1024)
+	nodes := exportNodes(t, tree)
+
+	// The cursor of an export after some nodes resumes the export right after them.
+	exporter := tree.Export()
+	for i := 0; i < 100; i++ {
+		_, err := exporter.Next()
+		require.NoError(t, err)
+	}
+	cursor := exporter.Cursor()
+	exporter.Close()
+	require.Equal(t, int64(100), cursor.Position)
+
+	for _, position := range []int64{0, 1, cursor.Position, int64(len(nodes)) - 1, int64(len(nodes))} {
+		cursor := *cursor
+		cursor.Position = position
+		exporter, err := tree.ExportFromCursor(context.Background(), &cursor)
+		require.NoError(t, err)
+		resumed := []*ExportNode{}
+		for {
+			node, err := exporter.Next()
+			if err == ErrorExportDone {
+				break
+			}
+			require.NoError(t, err)
+			resumed = append(resumed, node)
+		}
+		require.Equal(t, int64(len(nodes)), exporter.Cursor().Position)
+		exporter.Close()
+		require.Equal(t, nodes[position:], resumed)
+	}
+
+	// The export is not resumed on another tree, nor out of its range.
+	for _, cursor := range []ExportCursor{
+		{Version: cursor.Version + 1, RootHash: cursor.RootHash},
+		{Version: cursor.Version, RootHash: []byte("other")},
+	} {
+		cursor := cursor
+		_, err := tree.ExportFromCursor(context.Background(), &cursor)
+		require.ErrorIs(t, err, ErrResumeMismatch)
+	}
+	for _, position := range []int64{-1, int64(len(nodes)) + 1} {
+		_, err := tree.ExportFromCursor(context.Background(), &ExportCursor{
+			Version: cursor.Version, RootHash: cursor.RootHash, Position: position,
+		})
+		require.Error(t, err)
+	}
+}
@@ -114,7 +114,11 @@ func (iter *FastIterator) Next() {
 
 	iter.valid = iter.valid && iter.fastIterator.Valid()
 	if iter.valid {
-		iter.nextFastNode, iter.err = fastnode.DeserializeNode(iter.fastIterator.Key()[1:], iter.fastIterator.Value())
+		var value []byte
+		value, iter.err = decompressBytes(iter.fastIterator.Value())
+		if iter.err == nil {
+			iter.nextFastNode, iter.err = fastnode.DeserializeNode(iter.fastIterator.Key()[1:], value)
+		}
 		iter.valid = iter.err == nil
 	}
 }
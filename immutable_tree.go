@@ -1,8 +1,10 @@
 package iavl
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	dbm "github.com/cosmos/cosmos-db"
 )
@@ -18,6 +20,8 @@ type ImmutableTree struct {
 	ndb                    *nodeDB
 	version                int64
 	skipFastStorageUpgrade bool
+	witness                *witnessRecorder // The witness being recorded by the tree, if any
+	meter                  Meter            // The meter counting the work of the tree, if any
 }
 
 // NewImmutableTree creates both in-memory and persistent instances
@@ -149,14 +153,20 @@ func (t *ImmutableTree) Has(key []byte) (bool, error) {
 
 // Hash returns the root hash.
 func (t *ImmutableTree) Hash() ([]byte, error) {
-	hash, _, err := t.root.hashWithCount()
+	hash, _, err := t.root.hashRecursively(t.meter)
 	return hash, err
 }
 
 // Export returns an iterator that exports tree nodes as ExportNodes. These nodes can be
 // imported with MutableTree.Import() to recreate an identical tree.
 func (t *ImmutableTree) Export() *Exporter {
-	return newExporter(t)
+	return newExporter(context.Background(), t, 0)
+}
+
+// ExportWithContext is Export, with a context. If the context is cancelled, the export stops
+// and Exporter.Next() returns ctx.Err().
+func (t *ImmutableTree) ExportWithContext(ctx context.Context) *Exporter {
+	return newExporter(ctx, t, 0)
 }
 
 // GetWithIndex returns the index and value of the specified key if it exists, or nil and the next index
@@ -180,8 +190,15 @@ func (t *ImmutableTree) Get(key []byte) ([]byte, error) {
 	if t.root == nil {
 		return nil, nil
 	}
+	defer observeLatency(t.ndb.metrics, OpGet, time.Now())
+	return t.get(key)
+}
+
+// get is Get for a non-empty tree, without measuring its latency.
+func (t *ImmutableTree) get(key []byte) ([]byte, error) {
+	t.ndb.touchKey(key)
 
-	if !t.skipFastStorageUpgrade {
+	if t.usesFastStorage() {
 		// attempt to get a FastNode directly from db/cache.
 		// if call fails, fall back to the original IAVL logic in place.
 		fastNode, err := t.ndb.GetFastNode(key)
@@ -194,7 +211,7 @@ func (t *ImmutableTree) Get(key []byte) ([]byte, error) {
 			// If the tree is of the latest version and fast node is not in the tree
 			// then the regular node is not in the tree either because fast node
 			// represents live state.
-			if t.version == t.ndb.latestVersion {
+			if t.version == t.ndb.latestVersion && !t.ndb.followsWriter {
 				return nil, nil
 			}
 
@@ -226,6 +243,12 @@ func (t *ImmutableTree) GetByIndex(index int64) (key []byte, value []byte, err e
 // Iterate iterates over all keys of the tree. The keys and values must not be modified,
 // since they may point to data stored within IAVL. Returns true if stopped by callback, false otherwise
 func (t *ImmutableTree) Iterate(fn func(key []byte, value []byte) bool) (bool, error) {
+	return t.IterateWithContext(context.Background(), fn)
+}
+
+// IterateWithContext is Iterate, with a context. If the context is cancelled, the iteration
+// stops before the next key and ctx.Err() is returned.
+func (t *ImmutableTree) IterateWithContext(ctx context.Context, fn func(key []byte, value []byte) bool) (bool, error) {
 	if t.root == nil {
 		return false, nil
 	}
@@ -236,7 +259,15 @@ func (t *ImmutableTree) Iterate(fn func(key []byte, value []byte) bool) (bool, e
 	}
 	defer itr.Close()
 
+	return iterateWithContext(ctx, itr, fn)
+}
+
+// iterateWithContext calls fn for each key of itr, until fn returns true or ctx is cancelled.
+func iterateWithContext(ctx context.Context, itr dbm.Iterator, fn func(key []byte, value []byte) bool) (bool, error) {
 	for ; itr.Valid(); itr.Next() {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 		if fn(itr.Key(), itr.Value()) {
 			return true, nil
 		}
@@ -246,7 +277,7 @@ func (t *ImmutableTree) Iterate(fn func(key []byte, value []byte) bool) (bool, e
 
 // Iterator returns an iterator over the immutable tree.
 func (t *ImmutableTree) Iterator(start, end []byte, ascending bool) (dbm.Iterator, error) {
-	if !t.skipFastStorageUpgrade {
+	if t.usesFastStorage() {
 		isFastCacheEnabled, err := t.IsFastCacheEnabled()
 		if err != nil {
 			return nil, err
@@ -301,7 +332,18 @@ func (t *ImmutableTree) IsFastCacheEnabled() (bool, error) {
 	return isLatestTreeVersion && t.ndb.hasUpgradedToFastStorage(), nil
 }
 
+// usesFastStorage returns whether the reads of the tree may use fast storage. They traverse the
+// tree instead if fast storage is skipped, and while the tree records a witness or is metered, so
+// that the nodes read are recorded and counted.
+func (t *ImmutableTree) usesFastStorage() bool {
+	return !t.skipFastStorageUpgrade && t.witness == nil && t.meter == nil
+}
+
 func (t *ImmutableTree) isLatestTreeVersion() (bool, error) {
+	if t.ndb.followsWriter {
+		// The writer may have saved a newer version since.
+		return false, nil
+	}
 	latestVersion, err := t.ndb.getLatestVersion()
 	if err != nil {
 		return false, err
@@ -320,6 +362,14 @@ func (t *ImmutableTree) clone() *ImmutableTree {
 	}
 }
 
+// cloneWorking is clone, keeping the witness and the meter of the working tree in the clone.
+func (t *ImmutableTree) cloneWorking() *ImmutableTree {
+	clone := t.clone()
+	clone.witness = t.witness
+	clone.meter = t.meter
+	return clone
+}
+
 // nodeSize is like Size, but includes inner nodes too.
 //
 //nolint:unused
@@ -6,18 +6,37 @@ import (
 	"fmt"
 
 	db "github.com/cosmos/cosmos-db"
+
+	"github.com/cosmos/iavl/internal/encoding"
 )
 
 // maxBatchSize is the maximum size of the import batch before flushing it to the database
-const maxBatchSize = 10000
+var maxBatchSize uint32 = 10000
+
+// importCheckpointKey is the metadata entry holding the progress of a resumable import, written
+// along with each batch of nodes and deleted by Importer.Commit.
+const importCheckpointKey = "import_checkpoint"
 
 // ErrNoImport is returned when calling methods on a closed importer
 var ErrNoImport = errors.New("no import in progress")
 
+// ErrInvalidImport is returned by a verified import when the imported nodes are inconsistent with
+// each other or with the expected root hash.
+var ErrInvalidImport = errors.New("invalid import")
+
 // Importer imports data into an empty MutableTree. It is created by MutableTree.Import(). Users
 // must call Close() when done.
 //
 // ExportNodes must be imported in the order returned by Exporter, i.e. depth-first post-order (LRN).
+// Alternatively, the chunks of an export split by ImmutableTree.ExportChunks can be imported in
+// any order with AddChunk.
+//
+// An import created by MutableTree.ImportVerified or MutableTree.ImportResumable is verified
+// against the expected root hash of the tree, see ImportVerified.
+//
+// An import created by MutableTree.ImportResumable persists its progress with each batch of nodes,
+// and can be resumed after Close or a crash by calling ImportResumable again, and by resuming the
+// export at Cursor.
 //
 // Importer is not concurrency-safe, it is the caller's responsibility to ensure the tree is not
 // modified while performing an import.
@@ -27,6 +46,27 @@ type Importer struct {
 	batch     db.Batch
 	batchSize uint32
 	stack     []*Node
+
+	// The expected root hash of a verified or resumable import, and the progress of a resumable
+	// import.
+	verified  bool
+	resumable bool
+	rootHash  []byte
+	position  int64 // The number of nodes added.
+
+	// The chunks imported by AddChunk, if any.
+	chunkCount    int                    // The number of chunks of the export.
+	chunkVersion  int64                  // The version of the exported tree.
+	chunkRootHash []byte                 // The root hash of the exported tree.
+	nextChunk     int                    // The index of the next chunk to assemble.
+	chunks        map[int]*importedChunk // The imported chunks waiting for the previous ones.
+}
+
+// importedChunk is a chunk whose subtree has been imported, and whose ancestors are waiting for
+// the previous chunks to be imported.
+type importedChunk struct {
+	root      *Node
+	ancestors []*ExportNode
 }
 
 // newImporter creates a new Importer for an empty MutableTree.
@@ -52,9 +92,162 @@ func newImporter(tree *MutableTree, version int64) (*Importer, error) {
 	}, nil
 }
 
+/*
+ImportVerified is Import, verifying that the imported nodes recreate the tree of the given root
+hash. Each node is checked as it is added, which rejects a stream of nodes that cannot make up a
+well-formed tree at the first malformed node:
+
+  - an inner node must have two children, its height must be one more than the height of the
+    tallest child, and the heights of its children may differ by one at most;
+  - the key of an inner node must be the first key of its right subtree, and the last key of its
+    left subtree must be before it;
+  - the version of a node must be positive, not newer than the imported version, and not older
+    than the versions of its children;
+  - no node may be added once the nodes added make up the tree of the expected root hash, and
+    the chunks added by AddChunk must come from an export of that tree.
+
+These checks only catch malformed structure. No intermediate hash can be trusted, so a
+well-formed stream with wrong keys, values or versions is only detected once the root hash of the
+imported tree is known: Commit fails unless it is the expected root hash. The errors rejecting
+nodes, chunks or the imported tree, including the checks of Import such as a node version above
+the imported version, wrap ErrInvalidImport and leave the in-memory state of the import unchanged.
+*/
+func (tree *MutableTree) ImportVerified(version int64, rootHash []byte) (*Importer, error) {
+	importer, err := newImporter(tree, version)
+	if err != nil {
+		return nil, err
+	}
+	importer.verified = true
+	importer.rootHash = rootHash
+	return importer, nil
+}
+
+// ImportResumable is ImportVerified, for an export of the tree of the given version and root hash which
+// can be resumed: if the database holds the checkpoint of an import, the import resumes from it
+// and Importer.Cursor returns the position at which to resume the export. Resuming the import of
+// another version or root hash fails with ErrResumeMismatch; to start over, import into an empty
+// database instead.
+func (tree *MutableTree) ImportResumable(version int64, rootHash []byte) (*Importer, error) {
+	importer, err := newImporter(tree, version)
+	if err != nil {
+		return nil, err
+	}
+	importer.verified = true
+	importer.rootHash = rootHash
+	if err := importer.loadCheckpoint(); err != nil {
+		importer.Close()
+		return nil, err
+	}
+	importer.resumable = true
+	return importer, nil
+}
+
+// loadCheckpoint restores the progress of the import from the checkpoint in the database, if any.
+func (i *Importer) loadCheckpoint() error {
+	bz, err := i.tree.ndb.db.Get(metadataKeyFormat.Key([]byte(importCheckpointKey)))
+	if err != nil || bz == nil {
+		return err
+	}
+	d := &proofDecoder{bz: bz}
+	version := d.varint()
+	rootHash := d.bytes()
+	position := d.varint()
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.bz)) {
+		d.err = fmt.Errorf("invalid stack size %d", n)
+	}
+	hashes := make([][]byte, 0, n)
+	for j := uint64(0); j < n && d.err == nil; j++ {
+		hashes = append(hashes, d.bytes())
+	}
+	if d.err == nil && len(d.bz) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.bz))
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode import checkpoint: %w", d.err)
+	}
+	if version != i.version || !bytes.Equal(rootHash, i.rootHash) {
+		return fmt.Errorf("%w: checkpoint of version %d with root hash %X, import of version %d with root hash %X",
+			ErrResumeMismatch, version, rootHash, i.version, i.rootHash)
+	}
+
+	for _, hash := range hashes {
+		node, err := i.tree.ndb.GetNode(hash)
+		if err != nil {
+			return fmt.Errorf("failed to load import checkpoint: %w", err)
+		}
+		i.stack = append(i.stack, node)
+	}
+	i.position = position
+	return nil
+}
+
+// checkpoint encodes the progress of the import.
+func (i *Importer) checkpoint() ([]byte, error) {
+	var buf bytes.Buffer
+	err := encoding.EncodeVarint(&buf, i.version)
+	if err == nil {
+		err = encoding.EncodeBytes(&buf, i.rootHash)
+	}
+	if err == nil {
+		err = encoding.EncodeVarint(&buf, i.position)
+	}
+	if err == nil {
+		err = encoding.EncodeUvarint(&buf, uint64(len(i.stack)))
+	}
+	for _, node := range i.stack {
+		if err == nil {
+			err = encoding.EncodeBytes(&buf, node.hash)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode import checkpoint: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBatch writes the batch to the database, along with the checkpoint of a resumable import,
+// and starts a new batch.
+func (i *Importer) writeBatch() error {
+	if i.resumable {
+		bz, err := i.checkpoint()
+		if err != nil {
+			return err
+		}
+		if err := i.batch.Set(metadataKeyFormat.Key([]byte(importCheckpointKey)), bz); err != nil {
+			return err
+		}
+	}
+	if err := i.batch.Write(); err != nil {
+		return err
+	}
+	i.batch.Close()
+	i.batch = i.tree.ndb.db.NewBatch()
+	i.batchSize = 0
+	return nil
+}
+
+// Cursor returns the position of the export after the nodes added to the import, from which a
+// resumed import continues. The root hash of the cursor is only set for a resumable import.
+func (i *Importer) Cursor() *ExportCursor {
+	return &ExportCursor{
+		Version:  i.version,
+		RootHash: i.rootHash,
+		Position: i.position,
+	}
+}
+
 // Close frees all resources. It is safe to call multiple times. Uncommitted nodes may already have
-// been flushed to the database, but will not be visible.
+// been flushed to the database, but will not be visible. The progress of a resumable import is
+// written to the database, so that it can be resumed.
 func (i *Importer) Close() {
+	if i.resumable && i.tree != nil {
+		// The import can still be resumed from the previous checkpoint if this fails.
+		if err := i.writeBatch(); err != nil {
+			i.tree.ndb.logger.Error("failed to write import checkpoint", "version", i.version,
+				"position", i.position, "err", err)
+		}
+	}
 	if i.batch != nil {
 		i.batch.Close()
 	}
@@ -69,12 +262,35 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 	if i.tree == nil {
 		return ErrNoImport
 	}
+	if i.chunks != nil {
+		return errors.New("cannot add nodes to an import of chunks")
+	}
+	if err := i.add(&i.stack, exportNode); err != nil {
+		return err
+	}
+	i.position++
+	return i.flush()
+}
+
+// flush writes the batch to the database once it is full.
+func (i *Importer) flush() error {
+	if i.batchSize < maxBatchSize {
+		return nil
+	}
+	return i.writeBatch()
+}
+
+// add imports exportNode, resolving its children from stack and pushing it onto the stack.
+func (i *Importer) add(stack *[]*Node, exportNode *ExportNode) error {
 	if exportNode == nil {
-		return errors.New("node cannot be nil")
+		return i.invalid(errors.New("node cannot be nil"))
 	}
 	if exportNode.Version > i.version {
-		return fmt.Errorf("node version %v can't be greater than import version %v",
-			exportNode.Version, i.version)
+		return i.invalid(fmt.Errorf("node version %v can't be greater than import version %v",
+			exportNode.Version, i.version))
+	}
+	if i.verified && len(*stack) == 1 && bytes.Equal((*stack)[0].hash, i.rootHash) {
+		return fmt.Errorf("%w: node added after the root %X", ErrInvalidImport, i.rootHash)
 	}
 
 	node := &Node{
@@ -91,15 +307,15 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 	//
 	// We don't modify the stack until we've verified the built node, to avoid leaving the
 	// importer in an inconsistent state when we return an error.
-	stackSize := len(i.stack)
+	stackSize := len(*stack)
 	switch {
-	case stackSize >= 2 && i.stack[stackSize-1].subtreeHeight < node.subtreeHeight && i.stack[stackSize-2].subtreeHeight < node.subtreeHeight:
-		node.leftNode = i.stack[stackSize-2]
+	case stackSize >= 2 && (*stack)[stackSize-1].subtreeHeight < node.subtreeHeight && (*stack)[stackSize-2].subtreeHeight < node.subtreeHeight:
+		node.leftNode = (*stack)[stackSize-2]
 		node.leftHash = node.leftNode.hash
-		node.rightNode = i.stack[stackSize-1]
+		node.rightNode = (*stack)[stackSize-1]
 		node.rightHash = node.rightNode.hash
-	case stackSize >= 1 && i.stack[stackSize-1].subtreeHeight < node.subtreeHeight:
-		node.leftNode = i.stack[stackSize-1]
+	case stackSize >= 1 && (*stack)[stackSize-1].subtreeHeight < node.subtreeHeight:
+		node.leftNode = (*stack)[stackSize-1]
 		node.leftHash = node.leftNode.hash
 	}
 
@@ -120,7 +336,12 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 
 	err = node.validate()
 	if err != nil {
-		return err
+		return i.invalid(err)
+	}
+	if i.verified {
+		if err = i.verify(node); err != nil {
+			return err
+		}
 	}
 
 	buf := bufPool.Get().(*bytes.Buffer)
@@ -133,34 +354,190 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 
 	bytesCopy := make([]byte, buf.Len())
 	copy(bytesCopy, buf.Bytes())
+	bytesCopy, err = compressBytes(i.tree.ndb.opts.Compression, bytesCopy)
+	if err != nil {
+		return err
+	}
 
 	if err = i.batch.Set(i.tree.ndb.nodeKey(node.hash), bytesCopy); err != nil {
 		return err
 	}
 
 	i.batchSize++
-	if i.batchSize >= maxBatchSize {
-		err = i.batch.Write()
-		if err != nil {
-			return err
-		}
-		i.batch.Close()
-		i.batch = i.tree.ndb.db.NewBatch()
-		i.batchSize = 0
-	}
 
 	// Update the stack now that we know there were no errors
 	switch {
 	case node.leftHash != nil && node.rightHash != nil:
-		i.stack = i.stack[:stackSize-2]
+		*stack = (*stack)[:stackSize-2]
 	case node.leftHash != nil || node.rightHash != nil:
-		i.stack = i.stack[:stackSize-1]
+		*stack = (*stack)[:stackSize-1]
 	}
-	i.stack = append(i.stack, node)
+	*stack = append(*stack, node)
 
 	return nil
 }
 
+// invalid returns err, the rejection of an imported node, wrapping ErrInvalidImport for a
+// verified import.
+func (i *Importer) invalid(err error) error {
+	if i.verified {
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	return err
+}
+
+// verify checks the invariants of a verified import on node, whose children are resolved.
+func (i *Importer) verify(node *Node) error {
+	if node.isLeaf() {
+		return nil
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: inner node of height %d, version %d and key %X: %s", ErrInvalidImport,
+			node.subtreeHeight, node.version, node.key, fmt.Sprintf(format, args...))
+	}
+	if node.leftNode == nil || node.rightNode == nil {
+		return invalid("missing child")
+	}
+	left, right := node.leftNode, node.rightNode
+	if height := maxInt8(left.subtreeHeight, right.subtreeHeight) + 1; node.subtreeHeight != height {
+		return invalid("height must be %d", height)
+	}
+	if balance := left.subtreeHeight - right.subtreeHeight; balance < -1 || balance > 1 {
+		return invalid("unbalanced children of heights %d and %d", left.subtreeHeight, right.subtreeHeight)
+	}
+	if node.version < left.version || node.version < right.version {
+		return invalid("older than its children of versions %d and %d", left.version, right.version)
+	}
+	lastKey, err := i.edgeKey(left, false)
+	if err != nil {
+		return err
+	}
+	firstKey, err := i.edgeKey(right, true)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(node.key, firstKey) {
+		return invalid("key must be the first key %X of its right subtree", firstKey)
+	}
+	if bytes.Compare(lastKey, firstKey) >= 0 {
+		return invalid("last key %X of its left subtree must be before its key", lastKey)
+	}
+	return nil
+}
+
+// edgeKey returns the first or the last key of the subtree of node.
+func (i *Importer) edgeKey(node *Node, first bool) ([]byte, error) {
+	var err error
+	for err == nil && !node.isLeaf() {
+		if first {
+			node, err = node.getLeftNode(i.tree.ImmutableTree)
+		} else {
+			node, err = node.getRightNode(i.tree.ImmutableTree)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node.key, nil
+}
+
+// AddChunk adds a chunk of an export split by ImmutableTree.ExportChunks to the import. The
+// chunks can be added in any order: the subtree of each chunk is imported right away, while its
+// ancestors are imported once the chunks before it have been added. The chunks must all come
+// from the same export, and cannot be mixed with nodes added by Add. If a chunk is rejected,
+// the in-memory state of the import is left unchanged, but the nodes of its subtree may already
+// have been written to the database, where nothing references them.
+func (i *Importer) AddChunk(chunk *ExportChunk) error {
+	if i.tree == nil {
+		return ErrNoImport
+	}
+	if chunk == nil {
+		return errors.New("chunk cannot be nil")
+	}
+	if i.resumable {
+		return errors.New("cannot add chunks to a resumable import")
+	}
+	if i.chunks == nil {
+		if len(i.stack) > 0 {
+			return errors.New("cannot add chunks to an import of nodes")
+		}
+	} else {
+		if chunk.Count != i.chunkCount {
+			return fmt.Errorf("chunk count %d does not match the count %d of the previous chunks",
+				chunk.Count, i.chunkCount)
+		}
+		if chunk.Version != i.chunkVersion || !bytes.Equal(chunk.RootHash, i.chunkRootHash) {
+			return fmt.Errorf("chunk of version %d with root hash %X does not match the previous chunks of version %d with root hash %X",
+				chunk.Version, chunk.RootHash, i.chunkVersion, i.chunkRootHash)
+		}
+	}
+	if i.verified && !bytes.Equal(chunk.RootHash, i.rootHash) {
+		return fmt.Errorf("%w: chunk of root hash %X, expected %X", ErrInvalidImport, chunk.RootHash, i.rootHash)
+	}
+	if chunk.Index < 0 || chunk.Index >= chunk.Count {
+		return fmt.Errorf("chunk index %d out of range [0, %d)", chunk.Index, chunk.Count)
+	}
+	if _, ok := i.chunks[chunk.Index]; ok || chunk.Index < i.nextChunk {
+		return fmt.Errorf("chunk %d was already added", chunk.Index)
+	}
+
+	// The subtree of the chunk does not depend on other chunks, so it is imported on its own
+	// stack, ending with its root.
+	stack := make([]*Node, 0, 8)
+	for _, exportNode := range chunk.Subtree {
+		if err := i.add(&stack, exportNode); err != nil {
+			return err
+		}
+		if err := i.flush(); err != nil {
+			return err
+		}
+	}
+	if len(stack) != 1 {
+		return i.invalid(fmt.Errorf("invalid subtree of chunk %d, found stack size %v", chunk.Index, len(stack)))
+	}
+	imported := &importedChunk{root: stack[0], ancestors: chunk.Ancestors}
+
+	// Assemble the chunks which no longer wait for previous ones, as if their nodes were added.
+	// They are assembled on a copy of the stack, so that the import is left unchanged if one of
+	// their ancestors is invalid.
+	stack = append(make([]*Node, 0, len(i.stack)+1), i.stack...)
+	nextChunk := i.nextChunk
+	for {
+		next, ok := i.chunks[nextChunk]
+		if nextChunk == chunk.Index {
+			next, ok = imported, true
+		}
+		if !ok {
+			break
+		}
+		stack = append(stack, next.root)
+		for _, exportNode := range next.ancestors {
+			if err := i.add(&stack, exportNode); err != nil {
+				return err
+			}
+			if err := i.flush(); err != nil {
+				return err
+			}
+		}
+		nextChunk++
+	}
+
+	if i.chunks == nil {
+		i.chunkCount = chunk.Count
+		i.chunkVersion = chunk.Version
+		i.chunkRootHash = chunk.RootHash
+		i.chunks = make(map[int]*importedChunk)
+	}
+	if chunk.Index >= nextChunk {
+		i.chunks[chunk.Index] = imported
+	}
+	for ; i.nextChunk < nextChunk; i.nextChunk++ {
+		delete(i.chunks, i.nextChunk)
+	}
+	i.stack = stack
+	return nil
+}
+
 // Commit finalizes the import by flushing any outstanding nodes to the database, making the
 // version visible, and updating the tree metadata. It can only be called once, and calls Close()
 // internally.
@@ -168,6 +545,23 @@ func (i *Importer) Commit() error {
 	if i.tree == nil {
 		return ErrNoImport
 	}
+	if i.nextChunk < i.chunkCount {
+		return fmt.Errorf("missing chunk %d of %d", i.nextChunk, i.chunkCount)
+	}
+
+	if i.verified && len(i.stack) <= 1 {
+		var root *Node
+		if len(i.stack) == 1 {
+			root = i.stack[0]
+		}
+		hash, _, err := root.hashRecursively(nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, i.rootHash) {
+			return fmt.Errorf("%w: root hash %X, expected %X", ErrInvalidImport, hash, i.rootHash)
+		}
+	}
 
 	switch len(i.stack) {
 	case 0:
@@ -179,14 +573,19 @@ func (i *Importer) Commit() error {
 			return err
 		}
 	default:
-		return fmt.Errorf("invalid node structure, found stack size %v when committing",
-			len(i.stack))
+		return i.invalid(fmt.Errorf("invalid node structure, found stack size %v when committing",
+			len(i.stack)))
+	}
+	if err := i.batch.Delete(metadataKeyFormat.Key([]byte(importCheckpointKey))); err != nil {
+		return err
 	}
 
 	err := i.batch.WriteSync()
 	if err != nil {
 		return err
 	}
+	// The checkpoint is deleted, there is no progress left to write on Close.
+	i.resumable = false
 	i.tree.ndb.resetLatestVersion(i.version)
 
 	_, err = i.tree.LoadVersion(i.version)
@@ -1,6 +1,8 @@
 package iavl
 
 import (
+	"context"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -246,3 +248,351 @@ func BenchmarkImport(b *testing.B) {
 		require.NoError(b, err)
 	}
 }
+
+func TestImporter_AddChunk(t *testing.T) {
+	tree := setupExportTreeSized(t, 1024)
+	export, err := tree.ExportChunks(4)
+	require.NoError(t, err)
+	defer export.Close()
+	chunks := make([]*ExportChunk, export.Count())
+	for i := range chunks {
+		chunks[i], err = export.Chunk(context.Background(), i)
+		require.NoError(t, err)
+	}
+
+	// The chunks are imported in any order.
+	newTree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	importer, err := newTree.Import(tree.Version())
+	require.NoError(t, err)
+	defer importer.Close()
+	for n, i := range rand.New(rand.NewSource(1)).Perm(len(chunks)) {
+		require.NoError(t, importer.AddChunk(chunks[i]))
+		require.Error(t, importer.AddChunk(chunks[i]))
+		if n < len(chunks)-1 {
+			require.Error(t, importer.Commit())
+		}
+	}
+	require.NoError(t, importer.Commit())
+
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+	newHash, err := newTree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, newHash)
+	require.Equal(t, tree.Size(), newTree.Size())
+}
+
+func TestImporter_AddChunk_Errors(t *testing.T) {
+	tree := setupExportTreeBasic(t)
+	export, err := tree.ExportChunks(1)
+	require.NoError(t, err)
+	defer export.Close()
+	chunk, err := export.Chunk(context.Background(), 1)
+	require.NoError(t, err)
+
+	newImporter := func() *Importer {
+		newTree, err := NewMutableTree(db.NewMemDB(), 0, false)
+		require.NoError(t, err)
+		importer, err := newTree.Import(tree.Version())
+		require.NoError(t, err)
+		return importer
+	}
+
+	importer := newImporter()
+	require.Error(t, importer.AddChunk(nil))
+	require.Error(t, importer.AddChunk(&ExportChunk{Index: 2, Count: 2, Subtree: chunk.Subtree}))
+	require.Error(t, importer.AddChunk(&ExportChunk{Index: 0, Count: 2}))
+	require.NoError(t, importer.AddChunk(chunk))
+	require.Error(t, importer.AddChunk(&ExportChunk{Index: 0, Count: 3, Subtree: chunk.Subtree}))
+	require.Error(t, importer.Add(chunk.Subtree[0]))
+	require.Error(t, importer.Commit())
+	importer.Close()
+	require.ErrorIs(t, importer.AddChunk(chunk), ErrNoImport)
+
+	// Chunks cannot be mixed with nodes.
+	importer = newImporter()
+	defer importer.Close()
+	require.NoError(t, importer.Add(chunk.Subtree[0]))
+	require.Error(t, importer.AddChunk(chunk))
+}
+
+func TestImporter_Resume(t *testing.T) {
+	tree := setupExportTreeSized(t, 1024)
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+
+	// Import half of the nodes, then stop.
+	memDB := db.NewMemDB()
+	newTree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	importer, err := newTree.ImportResumable(tree.Version(), hash)
+	require.NoError(t, err)
+	exporter := tree.Export()
+	for i := 0; i < 1000; i++ {
+		node, err := exporter.Next()
+		require.NoError(t, err)
+		require.NoError(t, importer.Add(node))
+	}
+	exporter.Close()
+	importer.Close()
+
+	// The import of another version or root hash is not resumed.
+	newTree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = newTree.ImportResumable(tree.Version()+1, hash)
+	require.ErrorIs(t, err, ErrResumeMismatch)
+	_, err = newTree.ImportResumable(tree.Version(), []byte("other"))
+	require.ErrorIs(t, err, ErrResumeMismatch)
+
+	// Resume the import where it stopped, along with the export.
+	importer, err = newTree.ImportResumable(tree.Version(), hash)
+	require.NoError(t, err)
+	defer importer.Close()
+	cursor := importer.Cursor()
+	require.Equal(t, int64(1000), cursor.Position)
+	exporter, err = tree.ExportFromCursor(context.Background(), cursor)
+	require.NoError(t, err)
+	defer exporter.Close()
+	for {
+		node, err := exporter.Next()
+		if err == ErrorExportDone {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, importer.Add(node))
+	}
+	require.NoError(t, importer.Commit())
+
+	newHash, err := newTree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, newHash)
+	require.Equal(t, tree.Size(), newTree.Size())
+	checkpoint, err := memDB.Get(metadataKeyFormat.Key([]byte(importCheckpointKey)))
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+}
+
+func TestImporter_Verified(t *testing.T) {
+	tree := setupExportTreeRandom(t)
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+	nodes := exportNodes(t, tree)
+	inner := 0 // The first inner node, whose children are the two nodes before it.
+	for nodes[inner].Height == 0 {
+		inner++
+	}
+	root := len(nodes) - 1
+
+	testcases := map[string]struct {
+		corrupt func(nodes []*ExportNode) []*ExportNode
+		invalid int // The index of the first invalid node, or len(nodes) if detected on Commit.
+	}{
+		"valid": {func(nodes []*ExportNode) []*ExportNode { return nodes }, -1},
+		"leaf value": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[0].Value = []byte("other")
+			return nodes
+		}, len(nodes)},
+		"leaf order": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[inner-2].Key, nodes[inner-1].Key = nodes[inner-1].Key, nodes[inner-2].Key
+			return nodes
+		}, inner},
+		"inner key": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[inner].Key = nodes[inner-2].Key
+			return nodes
+		}, inner},
+		"inner height": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[inner].Height++
+			return nodes
+		}, inner},
+		"root version": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[root].Version = 1
+			return nodes
+		}, root},
+		"node after root": {func(nodes []*ExportNode) []*ExportNode {
+			return append(nodes, nodes[0])
+		}, len(nodes)},
+		"version 0": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[0].Version = 0
+			return nodes
+		}, 0},
+		"version above import": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[0].Version = tree.Version() + 1
+			return nodes
+		}, 0},
+		"leaf above shorter nodes": {func(nodes []*ExportNode) []*ExportNode {
+			nodes[inner].Height = 0
+			return nodes
+		}, inner},
+	}
+	for desc, tc := range testcases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			corrupted := make([]*ExportNode, len(nodes))
+			for i, node := range nodes {
+				node := *node
+				corrupted[i] = &node
+			}
+			corrupted = tc.corrupt(corrupted)
+
+			newTree, err := NewMutableTree(db.NewMemDB(), 0, false)
+			require.NoError(t, err)
+			importer, err := newTree.ImportVerified(tree.Version(), hash)
+			require.NoError(t, err)
+			defer importer.Close()
+			for i, node := range corrupted {
+				err := importer.Add(node)
+				if i == tc.invalid {
+					require.ErrorIs(t, err, ErrInvalidImport)
+					return
+				}
+				require.NoError(t, err)
+			}
+			err = importer.Commit()
+			if tc.invalid == len(nodes) {
+				require.ErrorIs(t, err, ErrInvalidImport)
+				return
+			}
+			require.NoError(t, err)
+			newHash, err := newTree.Hash()
+			require.NoError(t, err)
+			require.Equal(t, hash, newHash)
+		})
+	}
+
+	// Chunks are verified likewise.
+	export, err := tree.ExportChunks(0)
+	require.NoError(t, err)
+	defer export.Close()
+	chunk, err := export.Chunk(context.Background(), 0)
+	require.NoError(t, err)
+	other := setupExportTreeBasic(t)
+	otherExport, err := other.ExportChunks(0)
+	require.NoError(t, err)
+	defer otherExport.Close()
+	otherChunk, err := otherExport.Chunk(context.Background(), 0)
+	require.NoError(t, err)
+
+	newTree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	importer, err := newTree.ImportVerified(tree.Version(), hash)
+	require.NoError(t, err)
+	require.ErrorIs(t, importer.AddChunk(otherChunk), ErrInvalidImport)
+	extended := *chunk
+	extended.Subtree = append(append([]*ExportNode{}, chunk.Subtree...), chunk.Subtree[0])
+	require.ErrorIs(t, importer.AddChunk(&extended), ErrInvalidImport)
+	require.NoError(t, importer.AddChunk(chunk))
+	require.NoError(t, importer.Commit())
+
+	// An empty export is verified against the root hash of an empty tree.
+	newTree, err = NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	importer, err = newTree.ImportVerified(tree.Version(), hash)
+	require.NoError(t, err)
+	require.ErrorIs(t, importer.Commit(), ErrInvalidImport)
+	importer.Close()
+	emptyHash, err := NewImmutableTree(db.NewMemDB(), 0, false).Hash()
+	require.NoError(t, err)
+	importer, err = newTree.ImportVerified(tree.Version(), emptyHash)
+	require.NoError(t, err)
+	require.NoError(t, importer.Commit())
+}
+
+func TestImporter_AddChunk_Rejected(t *testing.T) {
+	tree := setupExportTreeSized(t, 64)
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+	export, err := tree.ExportChunks(1)
+	require.NoError(t, err)
+	defer export.Close()
+	chunks := make([]*ExportChunk, export.Count())
+	for i := range chunks {
+		chunks[i], err = export.Chunk(context.Background(), i)
+		require.NoError(t, err)
+		require.Equal(t, tree.Version(), chunks[i].Version)
+		require.Equal(t, hash, chunks[i].RootHash)
+	}
+	other := setupExportTreeSized(t, 16)
+	otherExport, err := other.ExportChunks(1)
+	require.NoError(t, err)
+	defer otherExport.Close()
+	otherChunk, err := otherExport.Chunk(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, len(chunks), otherChunk.Count)
+
+	newTree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	importer, err := newTree.Import(tree.Version())
+	require.NoError(t, err)
+	defer importer.Close()
+
+	// A rejected first chunk does not determine the count of the chunks.
+	require.Error(t, importer.AddChunk(&ExportChunk{Index: 0, Count: 5}))
+	require.NoError(t, importer.AddChunk(chunks[0]))
+
+	// A chunk of another export is rejected.
+	require.Error(t, importer.AddChunk(otherChunk))
+
+	// A chunk whose ancestors are invalid is rejected, and can then be added again.
+	corrupted := *chunks[1]
+	corrupted.Ancestors = []*ExportNode{{Key: nil, Version: 1, Height: chunks[1].Ancestors[0].Height}}
+	require.Error(t, importer.AddChunk(&corrupted))
+	require.NoError(t, importer.AddChunk(chunks[1]))
+	require.NoError(t, importer.Commit())
+
+	newHash, err := newTree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, newHash)
+}
+
+func TestImporter_Resume_Crash(t *testing.T) {
+	tmpMaxBatchSize := maxBatchSize
+	maxBatchSize = 100
+	defer func() {
+		maxBatchSize = tmpMaxBatchSize
+	}()
+
+	tree := setupExportTreeSized(t, 1024)
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+
+	// Import some of the nodes, and crash without closing the importer: only the batches
+	// flushed so far, along with their checkpoint, are in the database.
+	memDB := db.NewMemDB()
+	newTree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	importer, err := newTree.ImportResumable(tree.Version(), hash)
+	require.NoError(t, err)
+	exporter := tree.Export()
+	for i := 0; i < 950; i++ {
+		node, err := exporter.Next()
+		require.NoError(t, err)
+		require.NoError(t, importer.Add(node))
+	}
+	exporter.Close()
+
+	newTree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	importer, err = newTree.ImportResumable(tree.Version(), hash)
+	require.NoError(t, err)
+	defer importer.Close()
+	cursor := importer.Cursor()
+	require.Equal(t, int64(900), cursor.Position)
+	exporter, err = tree.ExportFromCursor(context.Background(), cursor)
+	require.NoError(t, err)
+	defer exporter.Close()
+	for {
+		node, err := exporter.Next()
+		if err == ErrorExportDone {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, importer.Add(node))
+	}
+	require.NoError(t, importer.Commit())
+
+	newHash, err := newTree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, newHash)
+	require.Equal(t, tree.Size(), newTree.Size())
+}
@@ -0,0 +1,24 @@
+package iavl
+
+// Logger is a leveled logger taking a message and alternating keys and values, e.g.
+// logger.Info("loaded version", "version", 42). It is satisfied by the loggers of
+// Tendermint and the Cosmos SDK.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+var _ Logger = nopLogger{}
+
+// NewNopLogger returns a Logger discarding all logs. It is used when no Logger is given
+// in the Options.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
@@ -0,0 +1,93 @@
+package iavl
+
+import (
+	"sync"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level   string
+	msg     string
+	keyvals map[string]interface{}
+}
+
+// recordingLogger records all logs, for testing.
+type recordingLogger struct {
+	mtx     sync.Mutex
+	entries []logEntry
+}
+
+var _ Logger = (*recordingLogger)(nil)
+
+func (l *recordingLogger) log(level, msg string, keyvals []interface{}) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if len(keyvals)%2 != 0 {
+		panic("odd number of keyvals in log " + msg)
+	}
+	entry := logEntry{level: level, msg: msg, keyvals: make(map[string]interface{})}
+	for i := 0; i < len(keyvals); i += 2 {
+		entry.keyvals[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.log("debug", msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.log("info", msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.log("error", msg, keyvals) }
+
+// find returns the first entry with the given message.
+func (l *recordingLogger) find(t *testing.T, msg string) logEntry {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for _, entry := range l.entries {
+		if entry.msg == msg {
+			return entry
+		}
+	}
+	require.Failf(t, "log not found", "message %q", msg)
+	return logEntry{}
+}
+
+func TestLogger(t *testing.T) {
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, true)
+	require.NoError(t, err)
+	for v := 0; v < 3; v++ {
+		for _, key := range []string{"a", "b", "c"} {
+			_, err = tree.Set([]byte(key), []byte{byte(v)})
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	logger := &recordingLogger{}
+	opts := DefaultOptions()
+	opts.Logger = logger
+	tree, err = NewMutableTreeWithOpts(memDB, 0, &opts, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+
+	entry := logger.find(t, "loaded version")
+	require.Equal(t, "info", entry.level)
+	require.EqualValues(t, 3, entry.keyvals["version"])
+	require.EqualValues(t, 3, entry.keyvals["versions"])
+	require.Contains(t, entry.keyvals, "duration")
+
+	entry = logger.find(t, "upgraded to fast storage")
+	require.EqualValues(t, 3, entry.keyvals["version"])
+	require.EqualValues(t, 3, entry.keyvals["fast_nodes"])
+
+	require.NoError(t, tree.DeleteVersionsRange(1, 3))
+	entry = logger.find(t, "deleted versions")
+	require.EqualValues(t, 1, entry.keyvals["from_version"])
+	require.EqualValues(t, 3, entry.keyvals["to_version"])
+	entry = logger.find(t, "deleted orphans")
+	require.Equal(t, "debug", entry.level)
+	require.Positive(t, entry.keyvals["deleted"])
+}
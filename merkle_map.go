@@ -0,0 +1,136 @@
+package iavl
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	ics23 "github.com/confio/ics23/go"
+)
+
+// A merkle map commits to a set of named values, such as the root hashes of the trees of a
+// MultiTree. Its root is computed like the simple merkle tree of Tendermint, so that it can be
+// proven with the ics23.TendermintSpec: the leaves are the length-prefixed pairs of the name and
+// the SHA-256 hash of the value, sorted by name, and they are split into a left subtree holding
+// the largest power of two strictly less than their number, and a right subtree holding the rest.
+
+var (
+	merkleLeafPrefix  = []byte{0}
+	merkleInnerPrefix = []byte{1}
+)
+
+// merkleMapRoot returns the root hash of the merkle map of values.
+func merkleMapRoot(values map[string][]byte) []byte {
+	return merkleRoot(merkleMapLeaves(values))
+}
+
+// merkleMapLeaves returns the leaf hashes of the merkle map of values, sorted by name.
+func merkleMapLeaves(values map[string][]byte) [][]byte {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	leaves := make([][]byte, len(names))
+	for i, name := range names {
+		leaves[i] = merkleLeafHash(merkleMapLeaf(name, values[name]))
+	}
+	return leaves
+}
+
+// merkleMapLeaf returns the encoding of the pair of name and value, as hashed into a leaf.
+func merkleMapLeaf(name string, value []byte) []byte {
+	valueHash := sha256.Sum256(value)
+	leaf := appendLengthPrefixed(nil, []byte(name))
+	return appendLengthPrefixed(leaf, valueHash[:])
+}
+
+func appendLengthPrefixed(bz, data []byte) []byte {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(len(data)))
+	bz = append(bz, length[:n]...)
+	return append(bz, data...)
+}
+
+func merkleLeafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write(merkleLeafPrefix)
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func merkleInnerHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(merkleInnerPrefix)
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleRoot returns the root hash of a simple merkle tree over the given leaf hashes.
+func merkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	default:
+		k := merkleSplitPoint(len(leaves))
+		return merkleInnerHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+	}
+}
+
+// merkleSplitPoint returns the largest power of two strictly less than n, for n > 1.
+func merkleSplitPoint(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// merkleMapExistenceProof returns an ics23 existence proof of the value named name in the merkle
+// map of values, to be verified with the ics23.TendermintSpec.
+func merkleMapExistenceProof(values map[string][]byte, name string) (*ics23.ExistenceProof, error) {
+	value, ok := values[name]
+	if !ok {
+		return nil, fmt.Errorf("no value named %q", name)
+	}
+	names := make([]string, 0, len(values))
+	for n := range values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	index := sort.SearchStrings(names, name)
+
+	return &ics23.ExistenceProof{
+		Key:   []byte(name),
+		Value: value,
+		Leaf:  ics23.TendermintSpec.LeafSpec,
+		Path:  merklePath(merkleMapLeaves(values), index),
+	}, nil
+}
+
+// merklePath returns the inner ops from the leaf at index up to the root of a simple merkle tree
+// over the given leaf hashes.
+func merklePath(leaves [][]byte, index int) []*ics23.InnerOp {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := merkleSplitPoint(len(leaves))
+	if index < k {
+		return append(merklePath(leaves[:k], index), &ics23.InnerOp{
+			Hash:   ics23.HashOp_SHA256,
+			Prefix: merkleInnerPrefix,
+			Suffix: merkleRoot(leaves[k:]),
+		})
+	}
+	prefix := append(append([]byte{}, merkleInnerPrefix...), merkleRoot(leaves[:k])...)
+	return append(merklePath(leaves[k:], index-k), &ics23.InnerOp{
+		Hash:   ics23.HashOp_SHA256,
+		Prefix: prefix,
+	})
+}
@@ -0,0 +1,60 @@
+package iavl
+
+// Meter counts the work done by the operations of a tree, e.g. for applications to charge gas
+// for it. Unlike Metrics, the counts do not depend on the state of the caches nor on whether fast
+// storage is enabled: the same operations on the same tree count the same on every node.
+//
+// A meter is called by the goroutine running an operation, so a meter shared by concurrent reads
+// of ImmutableTrees must be safe for concurrent use.
+type Meter interface {
+	// AddNodesTraversed counts the nodes reached from their parent by an operation, whether they
+	// are held in memory or not. The root, which every operation starts from, is not counted.
+	AddNodesTraversed(n int)
+
+	// AddNodesLoaded counts the nodes traversed which belong to saved versions, and are read from
+	// the database unless they are cached.
+	AddNodesLoaded(n int)
+
+	// AddBytesHashed counts the bytes hashed to compute the hashes of new nodes.
+	AddBytesHashed(n int)
+}
+
+// CountingMeter is a Meter keeping the counts. It is not safe for concurrent use.
+type CountingMeter struct {
+	NodesTraversed int64
+	NodesLoaded    int64
+	BytesHashed    int64
+}
+
+var _ Meter = (*CountingMeter)(nil)
+
+func (m *CountingMeter) AddNodesTraversed(n int) { m.NodesTraversed += int64(n) }
+func (m *CountingMeter) AddNodesLoaded(n int)    { m.NodesLoaded += int64(n) }
+func (m *CountingMeter) AddBytesHashed(n int)    { m.BytesHashed += int64(n) }
+
+// SetMeter attaches meter to the tree, counting the work of its reads, changes, iterators, proofs
+// and hashing, or detaches the attached meter if meter is nil. The reads of a metered tree
+// traverse the tree rather than use fast storage, so that their counts do not depend on it.
+//
+// On a MutableTree, the meter counts the work on the working tree, and stays attached through
+// saved, loaded and rolled back versions.
+func (t *ImmutableTree) SetMeter(meter Meter) {
+	t.meter = meter
+}
+
+// visit is called on each node reached from its parent by an operation of the tree. It records
+// the node in the witness being recorded, and counts it with the meter, if any.
+func (t *ImmutableTree) visit(node *Node) {
+	if t == nil {
+		return
+	}
+	if t.witness != nil {
+		t.witness.record(node)
+	}
+	if t.meter != nil {
+		t.meter.AddNodesTraversed(1)
+		if node.persisted {
+			t.meter.AddNodesLoaded(1)
+		}
+	}
+}
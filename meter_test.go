@@ -0,0 +1,79 @@
+package iavl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeter(t *testing.T) {
+	// The same tree, held in memory, loaded with an empty cache, and without fast storage.
+	newTree := func(cacheSize int, skipFastStorageUpgrade bool) (*MutableTree, db.DB) {
+		memDB := db.NewMemDB()
+		tree, err := NewMutableTree(memDB, cacheSize, skipFastStorageUpgrade)
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			_, err := tree.Set([]byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+		return tree, memDB
+	}
+	inMemory, _ := newTree(10000, false)
+	_, memDB := newTree(10000, false)
+	loaded, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = loaded.Load()
+	require.NoError(t, err)
+	noFastStorage, _ := newTree(0, true)
+
+	var meters []*CountingMeter
+	var hashes [][]byte
+	for _, tree := range []*MutableTree{inMemory, loaded, noFastStorage} {
+		meter := &CountingMeter{}
+		tree.SetMeter(meter)
+		for seed := int64(1); seed <= 2; seed++ {
+			executeBlock(t, tree, rand.New(rand.NewSource(seed)))
+			hash, _, err := tree.SaveVersion()
+			require.NoError(t, err)
+			hashes = append(hashes, hash)
+		}
+		meters = append(meters, meter)
+	}
+	require.Equal(t, hashes[:2], hashes[2:4])
+	require.Equal(t, hashes[:2], hashes[4:])
+
+	// The counts do not depend on the caches nor on fast storage.
+	require.Positive(t, meters[0].NodesTraversed)
+	require.Positive(t, meters[0].NodesLoaded)
+	require.Positive(t, meters[0].BytesHashed)
+	require.Less(t, meters[0].NodesLoaded, meters[0].NodesTraversed)
+	require.Equal(t, meters[0], meters[1])
+	require.Equal(t, meters[0], meters[2])
+
+	// A read of a saved version loads the nodes of the path to the key.
+	meter := &CountingMeter{}
+	loaded.SetMeter(meter)
+	value, err := loaded.Get([]byte("key0500"))
+	require.NoError(t, err)
+	require.NotNil(t, value)
+	require.Positive(t, meter.NodesTraversed)
+	require.LessOrEqual(t, meter.NodesTraversed, int64(loaded.Height()))
+	require.Equal(t, meter.NodesTraversed, meter.NodesLoaded)
+	require.Zero(t, meter.BytesHashed)
+
+	// Detached meters count no more.
+	counts := *meter
+	loaded.SetMeter(nil)
+	_, err = loaded.Get([]byte("key0600"))
+	require.NoError(t, err)
+	_, err = loaded.Set([]byte("key0600"), []byte("other"))
+	require.NoError(t, err)
+	_, _, err = loaded.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, counts, *meter)
+}
@@ -0,0 +1,82 @@
+package iavl
+
+import (
+	"time"
+)
+
+// Operation identifies a tree operation whose latency is measured.
+type Operation string
+
+const (
+	OpGet           Operation = "get"
+	OpSet           Operation = "set"
+	OpSaveVersion   Operation = "save_version"
+	OpDeleteVersion Operation = "delete_version"
+	OpLoadVersion   Operation = "load_version"
+)
+
+// CacheKind identifies one of the node caches of a tree.
+type CacheKind string
+
+const (
+	NodeCache     CacheKind = "node"
+	FastNodeCache CacheKind = "fast_node"
+)
+
+// Metrics receives measurements from a tree. Implementations must be safe for concurrent use,
+// since ImmutableTrees may be read concurrently.
+type Metrics interface {
+	// ObserveLatency records the duration of a call of Get, Set, SaveVersion, DeleteVersion,
+	// DeleteVersionsRange, LoadVersion or LazyLoadVersion.
+	ObserveLatency(op Operation, d time.Duration)
+
+	// ObserveCommit records the number of nodes and the number of bytes, including fast nodes,
+	// written to the database by a commit.
+	ObserveCommit(nodes, bytes int)
+
+	// AddOrphansCreated counts nodes orphaned by a new version.
+	AddOrphansCreated(n int)
+
+	// AddOrphansDeleted counts orphaned nodes deleted from the database along with the last
+	// version referencing them.
+	AddOrphansDeleted(n int)
+
+	// IncNodeDiskReads counts nodes read from the database, after missing the node cache.
+	IncNodeDiskReads()
+
+	// IncCacheHits counts lookups found in a cache.
+	IncCacheHits(cache CacheKind)
+
+	// IncCacheMisses counts lookups missing a cache.
+	IncCacheMisses(cache CacheKind)
+
+	// SetCacheSize records the number of nodes in a cache. It is called after each commit.
+	SetCacheSize(cache CacheKind, size int)
+}
+
+// NopMetrics discards all measurements. It is used when no Metrics are given in the Options.
+type NopMetrics struct{}
+
+var _ Metrics = NopMetrics{}
+
+func (NopMetrics) ObserveLatency(Operation, time.Duration) {}
+func (NopMetrics) ObserveCommit(int, int)                  {}
+func (NopMetrics) AddOrphansCreated(int)                   {}
+func (NopMetrics) AddOrphansDeleted(int)                   {}
+func (NopMetrics) IncNodeDiskReads()                       {}
+func (NopMetrics) IncCacheHits(CacheKind)                  {}
+func (NopMetrics) IncCacheMisses(CacheKind)                {}
+func (NopMetrics) SetCacheSize(CacheKind, int)             {}
+
+// observeLatency records the time elapsed since start. It is meant to be deferred.
+func observeLatency(m Metrics, op Operation, start time.Time) {
+	m.ObserveLatency(op, time.Since(start))
+}
+
+// pendingMetrics are measurements of writes staged in the batch, reported once committed.
+type pendingMetrics struct {
+	nodes          int
+	bytes          int
+	orphansCreated int
+	orphansDeleted int
+}
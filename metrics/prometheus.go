@@ -0,0 +1,142 @@
+// Package metrics provides adapters exposing the measurements of IAVL trees to metrics systems.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cosmos/iavl"
+)
+
+// Prometheus implements iavl.Metrics, exposing the measurements as Prometheus collectors.
+type Prometheus struct {
+	latency        *prometheus.HistogramVec
+	commitNodes    prometheus.Histogram
+	commitBytes    prometheus.Histogram
+	orphansCreated prometheus.Counter
+	orphansDeleted prometheus.Counter
+	nodeDiskReads  prometheus.Counter
+	cacheHits      *prometheus.CounterVec
+	cacheMisses    *prometheus.CounterVec
+	cacheSize      *prometheus.GaugeVec
+}
+
+var _ iavl.Metrics = (*Prometheus)(nil)
+
+// NewPrometheus creates the collectors under the given namespace, and registers them with
+// registerer. Trees sharing a registerer must be told apart by constLabels, e.g. a store name.
+func NewPrometheus(namespace string, constLabels prometheus.Labels, registerer prometheus.Registerer) (*Prometheus, error) {
+	const subsystem = "iavl"
+	m := &Prometheus{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "operation_duration_seconds",
+			Help:        "Duration of tree operations.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1e-6, 4, 12),
+		}, []string{"operation"}),
+		commitNodes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "commit_nodes",
+			Help:        "Number of nodes written per commit.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1, 4, 10),
+		}),
+		commitBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "commit_bytes",
+			Help:        "Number of bytes written per commit, including fast nodes.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(256, 4, 10),
+		}),
+		orphansCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "orphans_created_total",
+			Help:        "Number of nodes orphaned by new versions.",
+			ConstLabels: constLabels,
+		}),
+		orphansDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "orphans_deleted_total",
+			Help:        "Number of orphaned nodes deleted along with versions.",
+			ConstLabels: constLabels,
+		}),
+		nodeDiskReads: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "node_disk_reads_total",
+			Help:        "Number of nodes read from the database.",
+			ConstLabels: constLabels,
+		}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "cache_hits_total",
+			Help:        "Number of lookups found in a cache.",
+			ConstLabels: constLabels,
+		}, []string{"cache"}),
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "cache_misses_total",
+			Help:        "Number of lookups missing a cache.",
+			ConstLabels: constLabels,
+		}, []string{"cache"}),
+		cacheSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "cache_size",
+			Help:        "Number of nodes in a cache.",
+			ConstLabels: constLabels,
+		}, []string{"cache"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.latency, m.commitNodes, m.commitBytes, m.orphansCreated, m.orphansDeleted,
+		m.nodeDiskReads, m.cacheHits, m.cacheMisses, m.cacheSize,
+	} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Prometheus) ObserveLatency(op iavl.Operation, d time.Duration) {
+	m.latency.WithLabelValues(string(op)).Observe(d.Seconds())
+}
+
+func (m *Prometheus) ObserveCommit(nodes, bytes int) {
+	m.commitNodes.Observe(float64(nodes))
+	m.commitBytes.Observe(float64(bytes))
+}
+
+func (m *Prometheus) AddOrphansCreated(n int) {
+	m.orphansCreated.Add(float64(n))
+}
+
+func (m *Prometheus) AddOrphansDeleted(n int) {
+	m.orphansDeleted.Add(float64(n))
+}
+
+func (m *Prometheus) IncNodeDiskReads() {
+	m.nodeDiskReads.Inc()
+}
+
+func (m *Prometheus) IncCacheHits(cache iavl.CacheKind) {
+	m.cacheHits.WithLabelValues(string(cache)).Inc()
+}
+
+func (m *Prometheus) IncCacheMisses(cache iavl.CacheKind) {
+	m.cacheMisses.WithLabelValues(string(cache)).Inc()
+}
+
+func (m *Prometheus) SetCacheSize(cache iavl.CacheKind, size int) {
+	m.cacheSize.WithLabelValues(string(cache)).Set(float64(size))
+}
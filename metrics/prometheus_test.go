@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/iavl"
+)
+
+func TestPrometheus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewPrometheus("app", prometheus.Labels{"store": "bank"}, registry)
+	require.NoError(t, err)
+
+	opts := iavl.DefaultOptions()
+	opts.Metrics = m
+	tree, err := iavl.NewMutableTreeWithOpts(db.NewMemDB(), 1000, &opts, false)
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b", "c"} {
+		_, err = tree.Set([]byte(key), []byte(key))
+		require.NoError(t, err)
+	}
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	_, err = tree.Set([]byte("a"), []byte("updated"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	require.NoError(t, tree.DeleteVersion(1))
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.orphansCreated))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.orphansDeleted))
+	require.Equal(t, 5.0, testutil.ToFloat64(m.cacheSize.WithLabelValues("node")))
+	count, err := testutil.GatherAndCount(registry, "app_iavl_commit_nodes", "app_iavl_operation_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 1+3, count) // set, save_version and delete_version latencies
+
+	// The same collectors cannot be registered twice with the same labels.
+	_, err = NewPrometheus("app", prometheus.Labels{"store": "bank"}, registry)
+	require.Error(t, err)
+	_, err = NewPrometheus("app", prometheus.Labels{"store": "staking"}, registry)
+	require.NoError(t, err)
+}
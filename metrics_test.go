@@ -0,0 +1,140 @@
+package iavl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics records all measurements, for testing.
+type recordingMetrics struct {
+	mtx            sync.Mutex
+	latencies      map[Operation]int
+	commits        [][2]int
+	orphansCreated int
+	orphansDeleted int
+	diskReads      int
+	cacheHits      map[CacheKind]int
+	cacheMisses    map[CacheKind]int
+	cacheSizes     map[CacheKind]int
+}
+
+var _ Metrics = (*recordingMetrics)(nil)
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		latencies:   make(map[Operation]int),
+		cacheHits:   make(map[CacheKind]int),
+		cacheMisses: make(map[CacheKind]int),
+		cacheSizes:  make(map[CacheKind]int),
+	}
+}
+
+func (m *recordingMetrics) ObserveLatency(op Operation, d time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.latencies[op]++
+}
+
+func (m *recordingMetrics) ObserveCommit(nodes, bytes int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.commits = append(m.commits, [2]int{nodes, bytes})
+}
+
+func (m *recordingMetrics) AddOrphansCreated(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.orphansCreated += n
+}
+
+func (m *recordingMetrics) AddOrphansDeleted(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.orphansDeleted += n
+}
+
+func (m *recordingMetrics) IncNodeDiskReads() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.diskReads++
+}
+
+func (m *recordingMetrics) IncCacheHits(cache CacheKind) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.cacheHits[cache]++
+}
+
+func (m *recordingMetrics) IncCacheMisses(cache CacheKind) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.cacheMisses[cache]++
+}
+
+func (m *recordingMetrics) SetCacheSize(cache CacheKind, size int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.cacheSizes[cache] = size
+}
+
+func TestMetrics(t *testing.T) {
+	memDB := db.NewMemDB()
+	m := newRecordingMetrics()
+	opts := DefaultOptions()
+	opts.Metrics = m
+	tree, err := NewMutableTreeWithOpts(memDB, 1000, &opts, false)
+	require.NoError(t, err)
+
+	// Version 1 holds 3 leaves and 2 inner nodes.
+	for _, key := range []string{"a", "b", "c"} {
+		_, err = tree.Set([]byte(key), []byte(key))
+		require.NoError(t, err)
+	}
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, 3, m.latencies[OpSet])
+	require.Equal(t, 1, m.latencies[OpSaveVersion])
+	require.Len(t, m.commits, 1)
+	require.Equal(t, 5, m.commits[0][0])
+	require.Positive(t, m.commits[0][1])
+	require.Equal(t, 5, m.cacheSizes[NodeCache])
+	require.Equal(t, 3, m.cacheSizes[FastNodeCache])
+
+	// Updating a leaf orphans it, along with the root above it.
+	_, err = tree.Set([]byte("a"), []byte("updated"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, 2, m.commits[1][0])
+	require.Equal(t, 2, m.orphansCreated)
+
+	require.NoError(t, tree.DeleteVersion(1))
+	require.Equal(t, 1, m.latencies[OpDeleteVersion])
+	require.Equal(t, 2, m.orphansDeleted)
+
+	// Reads of a reloaded tree go to the database.
+	m = newRecordingMetrics()
+	opts.Metrics = m
+	tree, err = NewMutableTreeWithOpts(memDB, 1000, &opts, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	require.Equal(t, 1, m.latencies[OpLoadVersion])
+	require.Equal(t, 1, m.diskReads) // the root
+
+	value, err := tree.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), value)
+	require.Equal(t, 1, m.latencies[OpGet])
+	require.Equal(t, 1, m.cacheMisses[FastNodeCache])
+
+	_, _, err = tree.GetWithIndex([]byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, 3, m.diskReads)
+	require.Equal(t, 3, m.cacheMisses[NodeCache])
+	require.Zero(t, m.cacheHits[NodeCache])
+}
@@ -0,0 +1,437 @@
+package iavl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// multiTreePrefix is the prefix of the keys of each tree of a MultiTree, followed by its name
+// and a slash.
+const multiTreePrefix = "s/k:"
+
+// MultiTree is a set of named trees stored under distinct prefixes of a single database, and
+// versioned together. All trees are saved in a single atomic batch, and the MultiTree is
+// committed to by a root hash combining the root hashes of the trees in a merkle map.
+//
+// Like MutableTree, it is not safe for concurrent writes.
+type MultiTree struct {
+	db    dbm.DB
+	opts  Options
+	names []string // Sorted.
+	trees map[string]*MutableTree
+
+	// batch is the batch shared by all trees while they are being saved or their versions
+	// deleted, and nil otherwise.
+	batch dbm.Batch
+}
+
+// NewMultiTree returns a MultiTree made of one tree per name, created with the given cache
+// size and options. Names can neither be empty nor contain a slash. The trees must then be
+// loaded with Load or LoadVersion.
+func NewMultiTree(db dbm.DB, names []string, cacheSize int, opts *Options, skipFastStorageUpgrade bool) (*MultiTree, error) {
+	if opts == nil {
+		o := DefaultOptions()
+		opts = &o
+	}
+	m := &MultiTree{
+		db:    db,
+		opts:  *opts,
+		trees: make(map[string]*MutableTree, len(names)),
+	}
+	for _, name := range names {
+		if name == "" {
+			return nil, errors.New("tree name cannot be empty")
+		}
+		// The prefix of a name with a slash would be within the keys of another tree.
+		if strings.Contains(name, "/") {
+			return nil, fmt.Errorf("tree name %q cannot contain a slash", name)
+		}
+		if _, exists := m.trees[name]; exists {
+			return nil, fmt.Errorf("duplicate tree name %q", name)
+		}
+		prefix := []byte(multiTreePrefix + name + "/")
+		treeDB := &multiTreeDB{DB: dbm.NewPrefixDB(db, prefix), multiTree: m, prefix: prefix}
+		tree, err := NewMutableTreeWithOpts(treeDB, cacheSize, opts, skipFastStorageUpgrade)
+		if err != nil {
+			return nil, fmt.Errorf("creating tree %q, %w", name, err)
+		}
+		m.trees[name] = tree
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	return m, nil
+}
+
+// Names returns the names of the trees, in ascending order.
+func (m *MultiTree) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Tree returns the tree with the given name, or nil if there is none. The tree must not be
+// saved, loaded or have versions deleted directly, but only through the MultiTree.
+func (m *MultiTree) Tree(name string) *MutableTree {
+	return m.trees[name]
+}
+
+// Load loads the latest version of all trees.
+func (m *MultiTree) Load() (int64, error) {
+	return m.LoadVersion(0)
+}
+
+// LoadVersion loads the given version of all trees, or the latest one if version is 0. All trees
+// must be at the same version.
+func (m *MultiTree) LoadVersion(version int64) (int64, error) {
+	loaded := int64(-1)
+	for _, name := range m.names {
+		v, err := m.trees[name].LoadVersion(version)
+		if err != nil {
+			return 0, fmt.Errorf("loading tree %q, %w", name, err)
+		}
+		if loaded >= 0 && v != loaded {
+			return 0, fmt.Errorf("tree %q is at version %d, but tree %q is at version %d",
+				name, v, m.names[0], loaded)
+		}
+		loaded = v
+	}
+	if loaded < 0 {
+		return 0, nil
+	}
+	return loaded, nil
+}
+
+// Version returns the latest saved version of the trees.
+func (m *MultiTree) Version() int64 {
+	if len(m.names) == 0 {
+		return 0
+	}
+	return m.trees[m.names[0]].Version()
+}
+
+// AvailableVersions returns all available versions in ascending order.
+func (m *MultiTree) AvailableVersions() []int {
+	if len(m.names) == 0 {
+		return nil
+	}
+	return m.trees[m.names[0]].AvailableVersions()
+}
+
+// VersionExists returns whether or not a version exists.
+func (m *MultiTree) VersionExists(version int64) bool {
+	if len(m.names) == 0 {
+		return false
+	}
+	return m.trees[m.names[0]].VersionExists(version)
+}
+
+// Hash returns the combined root hash of the latest saved version of the trees.
+func (m *MultiTree) Hash() ([]byte, error) {
+	return m.combineHashes((*MutableTree).Hash)
+}
+
+// WorkingHash returns the combined root hash of the working trees.
+func (m *MultiTree) WorkingHash() ([]byte, error) {
+	return m.combineHashes((*MutableTree).WorkingHash)
+}
+
+// RootHashes returns the root hashes of the latest saved version of the trees, by name.
+func (m *MultiTree) RootHashes() (map[string][]byte, error) {
+	hashes := make(map[string][]byte, len(m.names))
+	for _, name := range m.names {
+		hash, err := m.trees[name].Hash()
+		if err != nil {
+			return nil, fmt.Errorf("hashing tree %q, %w", name, err)
+		}
+		hashes[name] = hash
+	}
+	return hashes, nil
+}
+
+func (m *MultiTree) combineHashes(hash func(*MutableTree) ([]byte, error)) ([]byte, error) {
+	hashes := make(map[string][]byte, len(m.names))
+	for _, name := range m.names {
+		h, err := hash(m.trees[name])
+		if err != nil {
+			return nil, fmt.Errorf("hashing tree %q, %w", name, err)
+		}
+		hashes[name] = h
+	}
+	return merkleMapRoot(hashes), nil
+}
+
+// SaveVersion saves a new version of all trees in a single atomic batch, and returns the
+// combined root hash and the version. If it fails, nothing is written, and all trees are loaded
+// again from the database, discarding their unsaved changes.
+func (m *MultiTree) SaveVersion() ([]byte, int64, error) {
+	previous := m.Version()
+	fastKeys := make(map[string][]string, len(m.names))
+	for _, name := range m.names {
+		fastKeys[name] = m.trees[name].unsavedFastKeys()
+	}
+
+	version := int64(-1)
+	err := m.write(func(name string, tree *MutableTree) error {
+		_, v, err := tree.SaveVersion()
+		if err != nil {
+			return fmt.Errorf("saving tree %q, %w", name, err)
+		}
+		if version >= 0 && v != version {
+			return fmt.Errorf("tree %q saved version %d, but tree %q saved version %d",
+				name, v, m.names[0], version)
+		}
+		version = v
+		return nil
+	})
+	if err != nil {
+		// Some trees may have been saved in memory only, they are all loaded again rather than
+		// left out of step.
+		for _, name := range m.names {
+			if loadErr := m.trees[name].reload(previous, fastKeys[name]); loadErr != nil {
+				return nil, 0, fmt.Errorf("%w, and reloading tree %q failed, %v", err, name, loadErr)
+			}
+		}
+		return nil, 0, err
+	}
+	hash, err := m.Hash()
+	if err != nil {
+		return nil, 0, err
+	}
+	if version < 0 {
+		version = 0
+	}
+	return hash, version, nil
+}
+
+// DeleteVersion deletes a version of all trees in a single atomic batch. Nothing is deleted
+// unless the version can be deleted from every tree, and the unsaved changes of the trees are
+// kept either way.
+func (m *MultiTree) DeleteVersion(version int64) error {
+	check := func(name string, tree *MutableTree) error {
+		if err := tree.checkDeleteVersion(version); err != nil {
+			return fmt.Errorf("deleting version %d of tree %q, %w", version, name, err)
+		}
+		return nil
+	}
+	return m.deleteVersions(check, func(name string, tree *MutableTree) error {
+		if err := tree.DeleteVersion(version); err != nil {
+			return fmt.Errorf("deleting version %d of tree %q, %w", version, name, err)
+		}
+		return nil
+	})
+}
+
+// DeleteVersionsRange deletes the versions from fromVersion to toVersion (not inclusive) of all
+// trees in a single atomic batch. Nothing is deleted unless the versions can be deleted from
+// every tree, and the unsaved changes of the trees are kept either way.
+func (m *MultiTree) DeleteVersionsRange(fromVersion, toVersion int64) error {
+	check := func(name string, tree *MutableTree) error {
+		if err := tree.checkDeleteVersionsRange(fromVersion, toVersion); err != nil {
+			return fmt.Errorf("deleting versions %d to %d of tree %q, %w", fromVersion, toVersion, name, err)
+		}
+		return nil
+	}
+	return m.deleteVersions(check, func(name string, tree *MutableTree) error {
+		if err := tree.DeleteVersionsRange(fromVersion, toVersion); err != nil {
+			return fmt.Errorf("deleting versions %d to %d of tree %q, %w", fromVersion, toVersion, name, err)
+		}
+		return nil
+	})
+}
+
+// GetImmutable returns a saved version of all trees, for querying and proofs.
+func (m *MultiTree) GetImmutable(version int64) (*ImmutableMultiTree, error) {
+	trees := make(map[string]*ImmutableTree, len(m.names))
+	for _, name := range m.names {
+		tree, err := m.trees[name].GetImmutable(version)
+		if err != nil {
+			return nil, fmt.Errorf("loading version %d of tree %q, %w", version, name, err)
+		}
+		trees[name] = tree
+	}
+	return &ImmutableMultiTree{version: version, trees: trees}, nil
+}
+
+// deleteVersions checks each tree with check before any tree is changed, then deletes versions
+// by calling fn for each tree, with their writes going to a shared batch, which is then written
+// to the database. If fn or the write fails, the versions deleted from the trees in memory are
+// restored, so that they are not left out of step. The working trees are left untouched.
+func (m *MultiTree) deleteVersions(check, fn func(name string, tree *MutableTree) error) error {
+	versions := make(map[string]map[int64]bool, len(m.names))
+	for _, name := range m.names {
+		if err := check(name, m.trees[name]); err != nil {
+			return err
+		}
+		versions[name] = m.trees[name].copyVersions()
+	}
+
+	err := m.write(fn)
+	if err != nil {
+		for _, name := range m.names {
+			m.trees[name].discardBatch()
+			m.trees[name].restoreVersions(versions[name])
+		}
+	}
+	return err
+}
+
+// write calls fn for each tree, with their writes going to a shared batch, which is then
+// written to the database.
+func (m *MultiTree) write(fn func(name string, tree *MutableTree) error) error {
+	m.batch = m.db.NewBatch()
+	defer func() {
+		m.batch.Close()
+		m.batch = nil
+	}()
+
+	for _, name := range m.names {
+		if err := fn(name, m.trees[name]); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if m.opts.Sync {
+		err = m.batch.WriteSync()
+	} else {
+		err = m.batch.Write()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write batch, %w", err)
+	}
+	return nil
+}
+
+// ImmutableMultiTree is a saved version of the trees of a MultiTree, returned by
+// MultiTree.GetImmutable.
+type ImmutableMultiTree struct {
+	version int64
+	trees   map[string]*ImmutableTree
+}
+
+// Version returns the version of the trees.
+func (m *ImmutableMultiTree) Version() int64 {
+	return m.version
+}
+
+// Tree returns the tree with the given name, or nil if there is none.
+func (m *ImmutableMultiTree) Tree(name string) *ImmutableTree {
+	return m.trees[name]
+}
+
+// RootHashes returns the root hashes of the trees, by name.
+func (m *ImmutableMultiTree) RootHashes() (map[string][]byte, error) {
+	hashes := make(map[string][]byte, len(m.trees))
+	for name, tree := range m.trees {
+		hash, err := tree.Hash()
+		if err != nil {
+			return nil, fmt.Errorf("hashing tree %q, %w", name, err)
+		}
+		hashes[name] = hash
+	}
+	return hashes, nil
+}
+
+// Hash returns the combined root hash of the trees.
+func (m *ImmutableMultiTree) Hash() ([]byte, error) {
+	hashes, err := m.RootHashes()
+	if err != nil {
+		return nil, err
+	}
+	return merkleMapRoot(hashes), nil
+}
+
+// multiTreeDB is the prefixed database of a tree of a MultiTree. Its batches write to the batch
+// of the MultiTree while the trees are being committed, and to the prefixed database otherwise.
+type multiTreeDB struct {
+	dbm.DB
+	multiTree *MultiTree
+	prefix    []byte
+}
+
+var _ dbm.DB = (*multiTreeDB)(nil)
+
+func (d *multiTreeDB) NewBatch() dbm.Batch {
+	return &multiTreeBatch{db: d}
+}
+
+// multiTreeBatch is a batch of a multiTreeDB. It is bound on its first write, either to the
+// batch of the MultiTree if it is being committed, or to a batch of the prefixed database.
+type multiTreeBatch struct {
+	db     *multiTreeDB
+	batch  dbm.Batch
+	shared bool // Whether batch is the batch of the MultiTree, written by the MultiTree.
+}
+
+var _ dbm.Batch = (*multiTreeBatch)(nil)
+
+func (b *multiTreeBatch) bind() {
+	if b.batch != nil {
+		return
+	}
+	if shared := b.db.multiTree.batch; shared != nil {
+		b.batch = &prefixBatch{prefix: b.db.prefix, batch: shared}
+		b.shared = true
+	} else {
+		b.batch = b.db.DB.NewBatch()
+	}
+}
+
+func (b *multiTreeBatch) Set(key, value []byte) error {
+	b.bind()
+	return b.batch.Set(key, value)
+}
+
+func (b *multiTreeBatch) Delete(key []byte) error {
+	b.bind()
+	return b.batch.Delete(key)
+}
+
+func (b *multiTreeBatch) Write() error {
+	if b.batch == nil || b.shared {
+		return nil
+	}
+	return b.batch.Write()
+}
+
+func (b *multiTreeBatch) WriteSync() error {
+	if b.batch == nil || b.shared {
+		return nil
+	}
+	return b.batch.WriteSync()
+}
+
+func (b *multiTreeBatch) Close() error {
+	if b.batch == nil || b.shared {
+		return nil
+	}
+	return b.batch.Close()
+}
+
+// prefixBatch prefixes the keys written to a batch. It is neither written nor closed by the
+// tree, but by the MultiTree.
+type prefixBatch struct {
+	prefix []byte
+	batch  dbm.Batch
+}
+
+func (b *prefixBatch) key(key []byte) []byte {
+	pkey := make([]byte, len(b.prefix)+len(key))
+	copy(pkey, b.prefix)
+	copy(pkey[len(b.prefix):], key)
+	return pkey
+}
+
+func (b *prefixBatch) Set(key, value []byte) error {
+	return b.batch.Set(b.key(key), value)
+}
+
+func (b *prefixBatch) Delete(key []byte) error {
+	return b.batch.Delete(b.key(key))
+}
+
+func (b *prefixBatch) Write() error     { return nil }
+func (b *prefixBatch) WriteSync() error { return nil }
+func (b *prefixBatch) Close() error     { return nil }
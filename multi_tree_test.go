@@ -0,0 +1,251 @@
+package iavl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleMapRoot(t *testing.T) {
+	values := map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}
+	la := merkleLeafHash(merkleMapLeaf("a", []byte("1")))
+	lb := merkleLeafHash(merkleMapLeaf("b", []byte("2")))
+	lc := merkleLeafHash(merkleMapLeaf("c", []byte("3")))
+	require.Equal(t, merkleInnerHash(merkleInnerHash(la, lb), lc), merkleMapRoot(values))
+	require.Equal(t, la, merkleMapRoot(map[string][]byte{"a": []byte("1")}))
+	require.Len(t, merkleMapRoot(nil), 32)
+
+	require.Equal(t, 1, merkleSplitPoint(2))
+	require.Equal(t, 2, merkleSplitPoint(3))
+	require.Equal(t, 2, merkleSplitPoint(4))
+	require.Equal(t, 4, merkleSplitPoint(5))
+}
+
+func TestMultiTree(t *testing.T) {
+	memDB := db.NewMemDB()
+	m, err := NewMultiTree(memDB, []string{"bank", "acc", "staking"}, 0, nil, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"acc", "bank", "staking"}, m.Names())
+	version, err := m.Load()
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	for v := 1; v <= 3; v++ {
+		for _, name := range m.Names() {
+			_, err := m.Tree(name).Set([]byte(fmt.Sprintf("%s%d", name, v)), []byte{byte(v)})
+			require.NoError(t, err)
+		}
+		workingHash, err := m.WorkingHash()
+		require.NoError(t, err)
+		hash, version, err := m.SaveVersion()
+		require.NoError(t, err)
+		require.EqualValues(t, v, version)
+		require.Equal(t, workingHash, hash)
+
+		hashes, err := m.RootHashes()
+		require.NoError(t, err)
+		require.Len(t, hashes, 3)
+		require.Equal(t, merkleMapRoot(hashes), hash)
+	}
+	hash, err := m.Hash()
+	require.NoError(t, err)
+
+	// The trees are stored under their own prefix.
+	tree, err := NewMutableTree(db.NewPrefixDB(memDB, []byte("s/k:bank/")), 0, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	value, err := tree.Get([]byte("bank2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{2}, value)
+	value, err = tree.Get([]byte("acc2"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	// Deleting versions applies to all trees.
+	require.NoError(t, m.DeleteVersion(1))
+	require.Equal(t, []int{2, 3}, m.AvailableVersions())
+
+	m, err = NewMultiTree(memDB, []string{"staking", "bank", "acc"}, 0, nil, false)
+	require.NoError(t, err)
+	version, err = m.Load()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, version)
+	require.EqualValues(t, 3, m.Version())
+	reloadedHash, err := m.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, reloadedHash)
+	for _, name := range m.Names() {
+		require.Equal(t, []int{2, 3}, m.Tree(name).AvailableVersions())
+	}
+	require.False(t, m.VersionExists(1))
+
+	require.NoError(t, m.DeleteVersionsRange(2, 3))
+	require.Equal(t, []int{3}, m.AvailableVersions())
+	_, err = m.LoadVersion(3)
+	require.NoError(t, err)
+	for _, name := range m.Names() {
+		require.Equal(t, []int{3}, m.Tree(name).AvailableVersions())
+	}
+}
+
+func TestMultiTree_Names(t *testing.T) {
+	_, err := NewMultiTree(db.NewMemDB(), []string{"a", ""}, 0, nil, false)
+	require.Error(t, err)
+	_, err = NewMultiTree(db.NewMemDB(), []string{"a", "b", "a"}, 0, nil, false)
+	require.Error(t, err)
+	// Tree "a/r" would write within the root keys of tree "a".
+	_, err = NewMultiTree(db.NewMemDB(), []string{"a", "a/r"}, 0, nil, false)
+	require.Error(t, err)
+}
+
+func TestMultiTree_AtomicSave(t *testing.T) {
+	memDB := db.NewMemDB()
+	m, err := NewMultiTree(memDB, []string{"a", "b"}, 0, nil, false)
+	require.NoError(t, err)
+	_, err = m.Load()
+	require.NoError(t, err)
+	for _, name := range m.Names() {
+		_, err := m.Tree(name).Set([]byte("key"), []byte("value"))
+		require.NoError(t, err)
+	}
+	_, _, err = m.SaveVersion()
+	require.NoError(t, err)
+
+	// Save a conflicting version 2 of tree b behind the MultiTree's back, so that saving
+	// version 2 of the MultiTree fails on tree b, after tree a is saved.
+	tree, err := NewMutableTree(db.NewPrefixDB(memDB, []byte("s/k:b/")), 0, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	_, err = tree.Set([]byte("key"), []byte("other"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+
+	_, err = m.Tree("a").Set([]byte("key"), []byte("new"))
+	require.NoError(t, err)
+	_, _, err = m.SaveVersion()
+	require.Error(t, err)
+
+	// Tree a was loaded again, rather than left saved in memory only.
+	require.EqualValues(t, 1, m.Tree("a").Version())
+	require.Equal(t, []int{1}, m.Tree("a").AvailableVersions())
+	value, err := m.Tree("a").Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+
+	// Nothing was written for tree a.
+	tree, err = NewMutableTree(db.NewPrefixDB(memDB, []byte("s/k:a/")), 0, false)
+	require.NoError(t, err)
+	version, err := tree.Load()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, version)
+
+	// The trees are no longer in lock-step.
+	m, err = NewMultiTree(memDB, []string{"a", "b"}, 0, nil, false)
+	require.NoError(t, err)
+	_, err = m.Load()
+	require.Error(t, err)
+}
+
+func TestMultiTree_DeleteVersionWithReader(t *testing.T) {
+	m, err := NewMultiTree(db.NewMemDB(), []string{"a", "b", "c"}, 0, nil, false)
+	require.NoError(t, err)
+	_, err = m.Load()
+	require.NoError(t, err)
+	for v := 1; v <= 3; v++ {
+		for _, name := range m.Names() {
+			_, err := m.Tree(name).Set([]byte("key"), []byte{byte(v)})
+			require.NoError(t, err)
+		}
+		_, _, err = m.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	// A reader of one version of the second tree prevents the deletion from all trees.
+	m.Tree("b").ndb.incrVersionReaders(1)
+	require.Error(t, m.DeleteVersion(1))
+	require.Error(t, m.DeleteVersionsRange(1, 3))
+	for _, name := range m.Names() {
+		require.Equal(t, []int{1, 2, 3}, m.Tree(name).AvailableVersions())
+	}
+
+	m.Tree("b").ndb.decrVersionReaders(1)
+	require.NoError(t, m.DeleteVersionsRange(1, 3))
+	for _, name := range m.Names() {
+		require.Equal(t, []int{3}, m.Tree(name).AvailableVersions())
+	}
+}
+
+// failingDB is a database whose batches fail to be written while fail is set.
+type failingDB struct {
+	db.DB
+	fail bool
+}
+
+func (d *failingDB) NewBatch() db.Batch {
+	return &failingBatch{Batch: d.DB.NewBatch(), db: d}
+}
+
+type failingBatch struct {
+	db.Batch
+	db *failingDB
+}
+
+func (b *failingBatch) Write() error {
+	if b.db.fail {
+		return errors.New("write failed")
+	}
+	return b.Batch.Write()
+}
+
+func (b *failingBatch) WriteSync() error {
+	if b.db.fail {
+		return errors.New("write failed")
+	}
+	return b.Batch.WriteSync()
+}
+
+func TestMultiTree_DeleteVersionWriteFailure(t *testing.T) {
+	failing := &failingDB{DB: db.NewMemDB()}
+	m, err := NewMultiTree(failing, []string{"a", "b"}, 0, nil, false)
+	require.NoError(t, err)
+	_, err = m.Load()
+	require.NoError(t, err)
+	for v := 1; v <= 3; v++ {
+		for _, name := range m.Names() {
+			_, err := m.Tree(name).Set([]byte("key"), []byte{byte(v)})
+			require.NoError(t, err)
+		}
+		_, _, err = m.SaveVersion()
+		require.NoError(t, err)
+	}
+	for _, name := range m.Names() {
+		_, err := m.Tree(name).Set([]byte("unsaved"), []byte("value"))
+		require.NoError(t, err)
+	}
+
+	// The failed deletion is undone in memory, and keeps the unsaved changes.
+	failing.fail = true
+	require.Error(t, m.DeleteVersion(1))
+	require.Error(t, m.DeleteVersionsRange(1, 3))
+	failing.fail = false
+	for _, name := range m.Names() {
+		require.Equal(t, []int{1, 2, 3}, m.Tree(name).AvailableVersions())
+		value, err := m.Tree(name).Get([]byte("unsaved"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), value)
+	}
+
+	_, version, err := m.SaveVersion()
+	require.NoError(t, err)
+	require.EqualValues(t, 4, version)
+	require.NoError(t, m.DeleteVersionsRange(1, 3))
+	for _, name := range m.Names() {
+		require.Equal(t, []int{3, 4}, m.Tree(name).AvailableVersions())
+	}
+}
@@ -2,16 +2,17 @@ package iavl
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	dbm "github.com/cosmos/cosmos-db"
 
 	"github.com/cosmos/iavl/fastnode"
-	"github.com/cosmos/iavl/internal/logger"
 )
 
 // commitGap after upgrade/delete commitGap FastNodes when commit the batch
@@ -27,7 +28,8 @@ var ErrVersionDoesNotExist = errors.New("version does not exist")
 // Given and returned key/value byte slices must not be modified, since they may point to data
 // located inside IAVL which would also be modified.
 //
-// The inner ImmutableTree should not be used directly by callers.
+// The inner ImmutableTree should not be used directly by callers. Its methods promoted to the
+// MutableTree, including proof generation, act on the working tree: see WorkingHash.
 type MutableTree struct {
 	*ImmutableTree                                     // The current, working tree.
 	lastSaved                *ImmutableTree            // The most recently saved tree.
@@ -37,7 +39,8 @@ type MutableTree struct {
 	unsavedFastNodeAdditions map[string]*fastnode.Node // FastNodes that have not yet been saved to disk
 	unsavedFastNodeRemovals  map[string]interface{}    // FastNodes that have not yet been removed from disk
 	ndb                      *nodeDB
-	skipFastStorageUpgrade   bool // If true, the tree will work like no fast storage and always not upgrade fast storage
+	skipFastStorageUpgrade   bool    // If true, the tree will work like no fast storage and always not upgrade fast storage
+	warmUp                   *warmUp // The cache warm-up started by the last load, if any
 
 	mtx sync.Mutex
 }
@@ -110,7 +113,10 @@ func (tree *MutableTree) Hash() ([]byte, error) {
 	return tree.lastSaved.Hash()
 }
 
-// WorkingHash returns the hash of the current working tree.
+// WorkingHash returns the hash of the current working tree. The proofs generated by the tree,
+// such as by GetMembershipProof, are of the working tree, unsaved changes included, and are
+// verified against this hash. They remain valid against the hash returned by SaveVersion, as long
+// as no further changes are made before saving.
 func (tree *MutableTree) WorkingHash() ([]byte, error) {
 	return tree.ImmutableTree.Hash()
 }
@@ -131,6 +137,8 @@ func (tree *MutableTree) prepareOrphansSlice() []*Node {
 // to slices stored within IAVL. It returns true when an existing value was
 // updated, while false means it was a new key.
 func (tree *MutableTree) Set(key, value []byte) (updated bool, err error) {
+	defer observeLatency(tree.ndb.metrics, OpSet, time.Now())
+	tree.ndb.touchKey(key)
 	var orphaned []*Node
 	orphaned, updated, err = tree.set(key, value)
 	if err != nil {
@@ -149,8 +157,9 @@ func (tree *MutableTree) Get(key []byte) ([]byte, error) {
 	if tree.root == nil {
 		return nil, nil
 	}
+	defer observeLatency(tree.ndb.metrics, OpGet, time.Now())
 
-	if !tree.skipFastStorageUpgrade {
+	if tree.usesFastStorage() {
 		if fastNode, ok := tree.unsavedFastNodeAdditions[unsafeToStr(key)]; ok {
 			return fastNode.GetValue(), nil
 		}
@@ -160,7 +169,7 @@ func (tree *MutableTree) Get(key []byte) ([]byte, error) {
 		}
 	}
 
-	return tree.ImmutableTree.Get(key)
+	return tree.ImmutableTree.get(key)
 }
 
 // Import returns an importer for tree nodes previously exported by ImmutableTree.Export(),
@@ -178,12 +187,18 @@ func (tree *MutableTree) Import(version int64) (*Importer, error) {
 // Iterate iterates over all keys of the tree. The keys and values must not be modified,
 // since they may point to data stored within IAVL. Returns true if stopped by callnack, false otherwise
 func (tree *MutableTree) Iterate(fn func(key []byte, value []byte) bool) (stopped bool, err error) {
+	return tree.IterateWithContext(context.Background(), fn)
+}
+
+// IterateWithContext is Iterate, with a context. If the context is cancelled, the iteration
+// stops before the next key and ctx.Err() is returned.
+func (tree *MutableTree) IterateWithContext(ctx context.Context, fn func(key []byte, value []byte) bool) (stopped bool, err error) {
 	if tree.root == nil {
 		return false, nil
 	}
 
-	if tree.skipFastStorageUpgrade {
-		return tree.ImmutableTree.Iterate(fn)
+	if !tree.usesFastStorage() {
+		return tree.ImmutableTree.IterateWithContext(ctx, fn)
 	}
 
 	isFastCacheEnabled, err := tree.IsFastCacheEnabled()
@@ -191,23 +206,18 @@ func (tree *MutableTree) Iterate(fn func(key []byte, value []byte) bool) (stoppe
 		return false, err
 	}
 	if !isFastCacheEnabled {
-		return tree.ImmutableTree.Iterate(fn)
+		return tree.ImmutableTree.IterateWithContext(ctx, fn)
 	}
 
 	itr := NewUnsavedFastIterator(nil, nil, true, tree.ndb, tree.unsavedFastNodeAdditions, tree.unsavedFastNodeRemovals)
 	defer itr.Close()
-	for ; itr.Valid(); itr.Next() {
-		if fn(itr.Key(), itr.Value()) {
-			return true, nil
-		}
-	}
-	return false, nil
+	return iterateWithContext(ctx, itr, fn)
 }
 
 // Iterator returns an iterator over the mutable tree.
 // CONTRACT: no updates are made to the tree while an iterator is active.
 func (tree *MutableTree) Iterator(start, end []byte, ascending bool) (dbm.Iterator, error) {
-	if !tree.skipFastStorageUpgrade {
+	if tree.usesFastStorage() {
 		isFastCacheEnabled, err := tree.IsFastCacheEnabled()
 		if err != nil {
 			return nil, err
@@ -235,8 +245,13 @@ func (tree *MutableTree) set(key []byte, value []byte) (orphans []*Node, updated
 	}
 
 	orphans = tree.prepareOrphansSlice()
-	tree.ImmutableTree.root, updated, err = tree.recursiveSet(tree.ImmutableTree.root, key, value, &orphans)
-	return orphans, updated, err
+	newRoot, updated, err := tree.recursiveSet(tree.ImmutableTree.root, key, value, &orphans)
+	if err != nil {
+		// The nodes of the working tree are cloned before being changed, so it is left as it was.
+		return nil, false, err
+	}
+	tree.ImmutableTree.root = newRoot
+	return orphans, updated, nil
 }
 
 func (tree *MutableTree) recursiveSet(node *Node, key []byte, value []byte, orphans *[]*Node) (
@@ -356,6 +371,7 @@ func (tree *MutableTree) remove(key []byte) (value []byte, orphaned []*Node, rem
 		if err != nil {
 			return nil, nil, false, err
 		}
+		tree.visit(tree.root)
 	} else {
 		tree.root = newRoot
 	}
@@ -468,6 +484,9 @@ func (tree *MutableTree) Load() (int64, error) {
 // performs a no-op. Otherwise, if the root does not exist, an error will be
 // returned.
 func (tree *MutableTree) LazyLoadVersion(targetVersion int64) (int64, error) {
+	ctx := context.Background()
+	start := time.Now()
+	defer observeLatency(tree.ndb.metrics, OpLoadVersion, start)
 	latestVersion, err := tree.ndb.getLatestVersion()
 	if err != nil {
 		return 0, err
@@ -482,7 +501,7 @@ func (tree *MutableTree) LazyLoadVersion(targetVersion int64) (int64, error) {
 			if !tree.skipFastStorageUpgrade {
 				tree.mtx.Lock()
 				defer tree.mtx.Unlock()
-				_, err := tree.enableFastStorageAndCommitIfNotEnabled()
+				_, err := tree.enableFastStorageAndCommitIfNotEnabled(ctx)
 				return 0, err
 			}
 			return 0, nil
@@ -512,6 +531,7 @@ func (tree *MutableTree) LazyLoadVersion(targetVersion int64) (int64, error) {
 		ndb:                    tree.ndb,
 		version:                targetVersion,
 		skipFastStorageUpgrade: tree.skipFastStorageUpgrade,
+		meter:                  tree.meter,
 	}
 	if len(rootHash) > 0 {
 		// If rootHash is empty then root of tree should be nil
@@ -528,16 +548,29 @@ func (tree *MutableTree) LazyLoadVersion(targetVersion int64) (int64, error) {
 
 	if !tree.skipFastStorageUpgrade {
 		// Attempt to upgrade
-		if _, err := tree.enableFastStorageAndCommitIfNotEnabled(); err != nil {
+		if _, err := tree.enableFastStorageAndCommitIfNotEnabled(ctx); err != nil {
 			return 0, err
 		}
 	}
 
+	tree.ndb.logger.Info("lazy loaded version", "version", targetVersion, "latest_version", latestVersion,
+		"duration", time.Since(start))
+	tree.startWarmUp()
+
 	return targetVersion, nil
 }
 
 // Returns the version number of the latest version found
 func (tree *MutableTree) LoadVersion(targetVersion int64) (int64, error) {
+	return tree.LoadVersionWithContext(context.Background(), targetVersion)
+}
+
+// LoadVersionWithContext is LoadVersion, with a context. If the context is cancelled during
+// the upgrade to fast storage, the upgrade is interrupted between two batches and ctx.Err()
+// is returned. The upgrade is then started over by the next load.
+func (tree *MutableTree) LoadVersionWithContext(ctx context.Context, targetVersion int64) (int64, error) {
+	start := time.Now()
+	defer observeLatency(tree.ndb.metrics, OpLoadVersion, start)
 	roots, err := tree.ndb.getRoots()
 	if err != nil {
 		return 0, err
@@ -548,7 +581,7 @@ func (tree *MutableTree) LoadVersion(targetVersion int64) (int64, error) {
 			if !tree.skipFastStorageUpgrade {
 				tree.mtx.Lock()
 				defer tree.mtx.Unlock()
-				_, err := tree.enableFastStorageAndCommitIfNotEnabled()
+				_, err := tree.enableFastStorageAndCommitIfNotEnabled(ctx)
 				return 0, err
 			}
 			return 0, nil
@@ -588,6 +621,7 @@ func (tree *MutableTree) LoadVersion(targetVersion int64) (int64, error) {
 		ndb:                    tree.ndb,
 		version:                latestVersion,
 		skipFastStorageUpgrade: tree.skipFastStorageUpgrade,
+		meter:                  tree.meter,
 	}
 
 	if len(latestRoot) != 0 {
@@ -604,14 +638,58 @@ func (tree *MutableTree) LoadVersion(targetVersion int64) (int64, error) {
 
 	if !tree.skipFastStorageUpgrade {
 		// Attempt to upgrade
-		if _, err := tree.enableFastStorageAndCommitIfNotEnabled(); err != nil {
+		if _, err := tree.enableFastStorageAndCommitIfNotEnabled(ctx); err != nil {
 			return 0, err
 		}
 	}
 
+	tree.ndb.logger.Info("loaded version", "version", latestVersion, "first_version", firstVersion,
+		"versions", len(tree.versions), "duration", time.Since(start))
+	tree.startWarmUp()
+
 	return latestVersion, nil
 }
 
+// startWarmUp starts the cache warm-up for the loaded tree, if enabled, stopping the
+// warm-up of a previous load.
+func (tree *MutableTree) startWarmUp() {
+	opts := tree.ndb.opts.WarmUp
+	if opts == nil || tree.root == nil {
+		return
+	}
+	if tree.warmUp != nil {
+		_ = tree.warmUp.stopAndWait()
+	}
+	tree.warmUp = startWarmUp(tree.lastSaved, opts)
+}
+
+// WaitForWarmUp blocks until the cache warm-up started by the last load, if any, is done,
+// and returns its error.
+func (tree *MutableTree) WaitForWarmUp() error {
+	if tree.warmUp == nil {
+		return nil
+	}
+	<-tree.warmUp.done
+	return tree.warmUp.err
+}
+
+// Close stops the cache warm-up if it is still running, and records the most recently used
+// keys for the warm-up of the next load, if enabled. It does not close the database.
+func (tree *MutableTree) Close() error {
+	var warmUpErr error
+	if tree.warmUp != nil {
+		warmUpErr = tree.warmUp.stopAndWait()
+		tree.warmUp = nil
+	}
+	if err := tree.ndb.saveRecentKeys(); err != nil {
+		return fmt.Errorf("failed to save recent keys, %w", err)
+	}
+	if warmUpErr != nil {
+		return fmt.Errorf("cache warm-up failed, %w", warmUpErr)
+	}
+	return nil
+}
+
 // LoadVersionForOverwriting attempts to load a tree at a previously committed
 // version, or the latest version below it. Any versions greater than targetVersion will be deleted.
 func (tree *MutableTree) LoadVersionForOverwriting(targetVersion int64) (int64, error) {
@@ -623,6 +701,7 @@ func (tree *MutableTree) LoadVersionForOverwriting(targetVersion int64) (int64,
 	if err = tree.ndb.DeleteVersionsFrom(targetVersion + 1); err != nil {
 		return latestVersion, err
 	}
+	tree.ndb.logger.Info("deleted versions for overwriting", "from_version", targetVersion+1)
 
 	if !tree.skipFastStorageUpgrade {
 		if err := tree.enableFastStorageAndCommitLocked(); err != nil {
@@ -659,7 +738,7 @@ func (tree *MutableTree) IsUpgradeable() (bool, error) {
 // Checks whether the fast cache on disk matches latest live state. If not, deletes all existing fast nodes and repopulates them
 // from latest tree.
 // nolint: unparam
-func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled() (bool, error) {
+func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled(ctx context.Context) (bool, error) {
 	isUpgradeable, err := tree.IsUpgradeable()
 	if err != nil {
 		return false, err
@@ -668,6 +747,8 @@ func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled() (bool, error)
 	if !isUpgradeable {
 		return false, nil
 	}
+	tree.ndb.logger.Info("upgrading to fast storage", "version", tree.version,
+		"storage_version", tree.ndb.getStorageVersion())
 
 	// If there is a mismatch between which fast nodes are on disk and the live state due to temporary
 	// downgrade and subsequent re-upgrade, we cannot know for sure which fast nodes have been removed while downgraded,
@@ -685,6 +766,9 @@ func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled() (bool, error)
 			if err := tree.ndb.Commit(); err != nil {
 				return false, err
 			}
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
 		}
 	}
 	if deletedFastNodes%commitGap != 0 {
@@ -692,9 +776,17 @@ func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled() (bool, error)
 			return false, err
 		}
 	}
+	if deletedFastNodes > 0 {
+		tree.ndb.logger.Info("deleted stale fast nodes", "count", deletedFastNodes)
+	}
 
-	if err := tree.enableFastStorageAndCommit(); err != nil {
+	if err := tree.enableFastStorageAndCommit(ctx); err != nil {
 		tree.ndb.storageVersion = defaultStorageVersionValue
+		if ctx.Err() != nil {
+			tree.ndb.logger.Info("fast storage upgrade interrupted", "version", tree.version, "err", err)
+		} else {
+			tree.ndb.logger.Error("fast storage upgrade failed", "version", tree.version, "err", err)
+		}
 		return false, err
 	}
 	return true, nil
@@ -703,11 +795,15 @@ func (tree *MutableTree) enableFastStorageAndCommitIfNotEnabled() (bool, error)
 func (tree *MutableTree) enableFastStorageAndCommitLocked() error {
 	tree.mtx.Lock()
 	defer tree.mtx.Unlock()
-	return tree.enableFastStorageAndCommit()
+	return tree.enableFastStorageAndCommit(context.Background())
 }
 
-func (tree *MutableTree) enableFastStorageAndCommit() error {
+// enableFastStorageAndCommit writes the fast nodes in batches, and marks the storage as upgraded
+// once all of them are written. If ctx is cancelled, it stops between two batches, leaving the
+// storage not upgraded.
+func (tree *MutableTree) enableFastStorageAndCommit(ctx context.Context) error {
 	var err error
+	start := time.Now()
 
 	itr := NewIterator(nil, nil, true, tree.ImmutableTree)
 	defer itr.Close()
@@ -722,6 +818,9 @@ func (tree *MutableTree) enableFastStorageAndCommit() error {
 			if err != nil {
 				return err
 			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -733,7 +832,12 @@ func (tree *MutableTree) enableFastStorageAndCommit() error {
 		return err
 	}
 
-	return tree.ndb.Commit()
+	if err = tree.ndb.Commit(); err != nil {
+		return err
+	}
+	tree.ndb.logger.Info("upgraded to fast storage", "version", tree.version, "fast_nodes", upgradedFastNodes,
+		"duration", time.Since(start))
+	return nil
 }
 
 // GetImmutable loads an ImmutableTree at a given version for querying. The returned tree is
@@ -774,6 +878,7 @@ func (tree *MutableTree) GetImmutable(version int64) (*ImmutableTree, error) {
 // Rollback resets the working tree to the latest saved version, discarding
 // any unsaved modifications.
 func (tree *MutableTree) Rollback() {
+	working := tree.ImmutableTree
 	if tree.version > 0 {
 		tree.ImmutableTree = tree.lastSaved.clone()
 	} else {
@@ -783,6 +888,7 @@ func (tree *MutableTree) Rollback() {
 			skipFastStorageUpgrade: tree.skipFastStorageUpgrade,
 		}
 	}
+	tree.ImmutableTree.witness, tree.ImmutableTree.meter = working.witness, working.meter
 	tree.orphans = map[string]int64{}
 	if !tree.skipFastStorageUpgrade {
 		tree.unsavedFastNodeAdditions = map[string]*fastnode.Node{}
@@ -827,6 +933,8 @@ func (tree *MutableTree) GetVersioned(key []byte, version int64) ([]byte, error)
 // SaveVersion saves a new tree version to disk, based on the current state of
 // the tree. Returns the hash and new version number.
 func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
+	start := time.Now()
+	defer observeLatency(tree.ndb.metrics, OpSaveVersion, start)
 	version := tree.version + 1
 	if version == 1 && tree.ndb.opts.InitialVersion > 0 {
 		version = int64(tree.ndb.opts.InitialVersion)
@@ -853,7 +961,7 @@ func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
 
 		if bytes.Equal(existingHash, newHash) {
 			tree.version = version
-			tree.ImmutableTree = tree.ImmutableTree.clone()
+			tree.ImmutableTree = tree.ImmutableTree.cloneWorking()
 			tree.lastSaved = tree.ImmutableTree.clone()
 			tree.orphans = map[string]int64{}
 			return existingHash, version, nil
@@ -865,7 +973,6 @@ func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
 	if tree.root == nil {
 		// There can still be orphans, for example if the root is the node being
 		// removed.
-		logger.Debug("SAVE EMPTY TREE %v\n", version)
 		if err := tree.ndb.SaveOrphans(version, tree.orphans); err != nil {
 			return nil, 0, err
 		}
@@ -873,7 +980,10 @@ func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
 			return nil, 0, err
 		}
 	} else {
-		logger.Debug("SAVE TREE %v\n", version)
+		// Hash the new nodes through the working tree, so that their hashing is metered.
+		if _, err := tree.WorkingHash(); err != nil {
+			return nil, 0, err
+		}
 		if _, err := tree.ndb.SaveBranch(tree.root); err != nil {
 			return nil, 0, err
 		}
@@ -901,7 +1011,7 @@ func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
 	tree.versions[version] = true
 
 	// set new working tree
-	tree.ImmutableTree = tree.ImmutableTree.clone()
+	tree.ImmutableTree = tree.ImmutableTree.cloneWorking()
 	tree.lastSaved = tree.ImmutableTree.clone()
 	tree.orphans = map[string]int64{}
 	if !tree.skipFastStorageUpgrade {
@@ -914,6 +1024,7 @@ func (tree *MutableTree) SaveVersion() ([]byte, int64, error) {
 		return nil, version, err
 	}
 
+	tree.ndb.logger.Debug("saved version", "version", version, "hash", hash, "duration", time.Since(start))
 	return hash, version, nil
 }
 
@@ -927,6 +1038,68 @@ func (tree *MutableTree) saveFastNodeVersion() error {
 	return tree.ndb.setFastStorageVersionToBatch()
 }
 
+// unsavedFastKeys returns the keys of the fast nodes changed since the last saved version.
+func (tree *MutableTree) unsavedFastKeys() []string {
+	keys := make([]string, 0, len(tree.unsavedFastNodeAdditions)+len(tree.unsavedFastNodeRemovals))
+	for key := range tree.unsavedFastNodeAdditions {
+		keys = append(keys, key)
+	}
+	for key := range tree.unsavedFastNodeRemovals {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// discardBatch drops the writes pending in the batch, after a write of the batch failed.
+func (tree *MutableTree) discardBatch() {
+	tree.ndb.mtx.Lock()
+	defer tree.ndb.mtx.Unlock()
+	tree.ndb.batch.Close()
+	tree.ndb.batch = tree.ndb.db.NewBatch()
+	tree.ndb.pending = pendingMetrics{}
+}
+
+// copyVersions returns a copy of the versions known to the tree, which can be restored with
+// restoreVersions.
+func (tree *MutableTree) copyVersions() map[int64]bool {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+	versions := make(map[int64]bool, len(tree.versions))
+	for version, exists := range tree.versions {
+		versions[version] = exists
+	}
+	return versions
+}
+
+// restoreVersions restores the versions known to the tree, e.g. after a failed deletion.
+func (tree *MutableTree) restoreVersions(versions map[int64]bool) {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+	tree.versions = versions
+}
+
+// reload loads version again after a write whose batch was dropped, discarding the state held in
+// memory which may not match the database: the batch, the cached fast nodes of fastKeys, the
+// versions, and the unsaved changes.
+func (tree *MutableTree) reload(version int64, fastKeys []string) error {
+	tree.discardBatch()
+	tree.ndb.mtx.Lock()
+	tree.ndb.latestVersion = 0 // Read again from the database.
+	for _, key := range fastKeys {
+		tree.ndb.fastNodeCache.Remove([]byte(key))
+	}
+	tree.ndb.mtx.Unlock()
+
+	tree.mtx.Lock()
+	tree.versions = make(map[int64]bool)
+	tree.unsavedFastNodeAdditions = make(map[string]*fastnode.Node)
+	tree.unsavedFastNodeRemovals = make(map[string]interface{})
+	tree.mtx.Unlock()
+
+	_, err := tree.LoadVersion(version)
+	return err
+}
+
 // nolint: unused
 func (tree *MutableTree) getUnsavedFastNodeAdditions() map[string]*fastnode.Node {
 	return tree.unsavedFastNodeAdditions
@@ -981,6 +1154,19 @@ func (tree *MutableTree) saveFastNodeRemovals() error {
 }
 
 func (tree *MutableTree) deleteVersion(version int64) error {
+	if err := tree.checkDeleteVersion(version); err != nil {
+		return err
+	}
+	if err := tree.ndb.DeleteVersion(version, true); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkDeleteVersion checks that version can be deleted by DeleteVersion, without changing
+// anything.
+func (tree *MutableTree) checkDeleteVersion(version int64) error {
 	if version <= 0 {
 		return errors.New("version must be greater than 0")
 	}
@@ -990,13 +1176,23 @@ func (tree *MutableTree) deleteVersion(version int64) error {
 	if !tree.VersionExists(version) {
 		return ErrVersionDoesNotExist
 	}
-	if err := tree.ndb.DeleteVersion(version, true); err != nil {
-		return err
+	tree.ndb.mtx.Lock()
+	defer tree.ndb.mtx.Unlock()
+	if readers := tree.ndb.versionReaders[version]; readers > 0 {
+		return fmt.Errorf("unable to delete version %v, it has %v active readers", version, readers)
 	}
-
 	return nil
 }
 
+// checkDeleteVersionsRange checks that the versions from fromVersion to toVersion (not
+// inclusive) can be deleted by DeleteVersionsRange, without changing anything.
+func (tree *MutableTree) checkDeleteVersionsRange(fromVersion, toVersion int64) error {
+	tree.ndb.mtx.Lock()
+	defer tree.ndb.mtx.Unlock()
+	_, err := tree.ndb.checkDeleteVersionsRange(fromVersion, toVersion)
+	return err
+}
+
 // SetInitialVersion sets the initial version of the tree, replacing Options.InitialVersion.
 // It is only used during the initial SaveVersion() call for a tree with no other versions,
 // and is otherwise ignored.
@@ -1007,8 +1203,6 @@ func (tree *MutableTree) SetInitialVersion(version uint64) {
 // DeleteVersions deletes a series of versions from the MutableTree.
 // Deprecated: please use DeleteVersionsRange instead.
 func (tree *MutableTree) DeleteVersions(versions ...int64) error {
-	logger.Debug("DELETING VERSIONS: %v\n", versions)
-
 	if len(versions) == 0 {
 		return nil
 	}
@@ -1036,32 +1230,50 @@ func (tree *MutableTree) DeleteVersions(versions ...int64) error {
 	return nil
 }
 
-// DeleteVersionsRange removes versions from an interval from the MutableTree (not inclusive).
-// An error is returned if any single version has active readers.
-// All writes happen in a single batch with a single commit.
-func (tree *MutableTree) DeleteVersionsRange(fromVersion, toVersion int64) error {
-	if err := tree.ndb.DeleteVersionsRange(fromVersion, toVersion); err != nil {
-		return err
-	}
-
-	if err := tree.ndb.Commit(); err != nil {
-		return err
+// DeleteVersionsRangeWithContext is DeleteVersionsRange, with a context, which is checked
+// between the commits of the deletion. If it is cancelled, the versions deleted so far remain
+// deleted, the others are kept, and ctx.Err() is returned.
+func (tree *MutableTree) DeleteVersionsRangeWithContext(ctx context.Context, fromVersion, toVersion int64) error {
+	start := time.Now()
+	defer observeLatency(tree.ndb.metrics, OpDeleteVersion, start)
+	err := tree.ndb.DeleteVersionsRange(ctx, fromVersion, toVersion)
+	if err == nil {
+		err = tree.ndb.Commit()
 	}
 
 	tree.mtx.Lock()
 	defer tree.mtx.Unlock()
 	for version := fromVersion; version < toVersion; version++ {
+		if err != nil {
+			// Only some of the versions may have been deleted.
+			if exists, hasErr := tree.ndb.HasRoot(version); hasErr != nil || exists {
+				continue
+			}
+		}
 		delete(tree.versions, version)
 	}
+	if err != nil {
+		return err
+	}
 
+	tree.ndb.logger.Info("deleted versions", "from_version", fromVersion, "to_version", toVersion,
+		"duration", time.Since(start))
 	return nil
 }
 
+// DeleteVersionsRange removes versions from an interval from the MutableTree (not inclusive).
+// An error is returned if any single version has active readers, before anything is deleted.
+// The writes are committed whenever commitGap orphans have been deleted, between two versions,
+// and otherwise happen in a single batch with a single commit.
+func (tree *MutableTree) DeleteVersionsRange(fromVersion, toVersion int64) error {
+	return tree.DeleteVersionsRangeWithContext(context.Background(), fromVersion, toVersion)
+}
+
 // DeleteVersion deletes a tree version from disk. The version can then no
 // longer be accessed.
 func (tree *MutableTree) DeleteVersion(version int64) error {
-	logger.Debug("DELETE VERSION: %d\n", version)
-
+	start := time.Now()
+	defer observeLatency(tree.ndb.metrics, OpDeleteVersion, start)
 	if err := tree.deleteVersion(version); err != nil {
 		return err
 	}
@@ -1073,6 +1285,8 @@ func (tree *MutableTree) DeleteVersion(version int64) error {
 	tree.mtx.Lock()
 	defer tree.mtx.Unlock()
 	delete(tree.versions, version)
+
+	tree.ndb.logger.Info("deleted version", "version", version, "duration", time.Since(start))
 	return nil
 }
 
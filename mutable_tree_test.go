@@ -2,6 +2,7 @@ package iavl
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -838,7 +839,7 @@ func TestUpgradeStorageToFast_LatestVersion_Success(t *testing.T) {
 	isUpgradeable, err := tree.IsUpgradeable()
 	require.True(t, isUpgradeable)
 	require.NoError(t, err)
-	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.True(t, enabled)
 	isUpgradeable, err = tree.IsUpgradeable()
@@ -869,7 +870,7 @@ func TestUpgradeStorageToFast_AlreadyUpgraded_Success(t *testing.T) {
 	isUpgradeable, err := tree.IsUpgradeable()
 	require.True(t, isUpgradeable)
 	require.NoError(t, err)
-	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.True(t, enabled)
 	isFastCacheEnabled, err = tree.IsFastCacheEnabled()
@@ -880,7 +881,7 @@ func TestUpgradeStorageToFast_AlreadyUpgraded_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test enabling fast storage when already enabled
-	enabled, err = tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err = tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.False(t, enabled)
 	isFastCacheEnabled, err = tree.IsFastCacheEnabled()
@@ -947,7 +948,7 @@ func TestUpgradeStorageToFast_DbErrorEnableFastStorage_Failure(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, isFastCacheEnabled)
 
-	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.ErrorIs(t, err, expectedError)
 	require.False(t, enabled)
 
@@ -998,7 +999,7 @@ func TestFastStorageReUpgradeProtection_NoForceUpgrade_Success(t *testing.T) {
 	require.False(t, shouldForce)
 	require.NoError(t, err)
 
-	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.False(t, enabled)
 }
@@ -1092,12 +1093,12 @@ func TestFastStorageReUpgradeProtection_ForceUpgradeFirstTime_NoForceSecondTime_
 	require.NoError(t, err)
 
 	// Actual method under test
-	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.True(t, enabled)
 
 	// Test that second time we call this, force upgrade does not happen
-	enabled, err = tree.enableFastStorageAndCommitIfNotEnabled()
+	enabled, err = tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 	require.NoError(t, err)
 	require.False(t, enabled)
 }
@@ -1253,7 +1254,7 @@ func TestUpgradeStorageToFast_Success(t *testing.T) {
 
 	for _, tt := range tests {
 		tree, mirror := setupTreeAndMirror(t, tt.fields.nodeCount, false)
-		enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+		enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 		require.Nil(t, err)
 		require.True(t, enabled)
 		t.Run(tt.name, func(t *testing.T) {
@@ -1311,7 +1312,7 @@ func TestUpgradeStorageToFast_Delete_Stale_Success(t *testing.T) {
 	for _, tt := range tests {
 		tree, mirror := setupTreeAndMirror(t, tt.fields.nodeCount, false)
 		addStaleKey(tree.ndb, tt.fields.staleCount)
-		enabled, err := tree.enableFastStorageAndCommitIfNotEnabled()
+		enabled, err := tree.enableFastStorageAndCommitIfNotEnabled(context.Background())
 		require.Nil(t, err)
 		require.True(t, enabled)
 		t.Run(tt.name, func(t *testing.T) {
@@ -1517,3 +1518,170 @@ func TestNoFastStorageUpgrade_Integration_SaveVersion_Load_Iterate_Success(t *te
 		})
 	})
 }
+
+func TestMutableTree_IterateWithContext(t *testing.T) {
+	for _, skipFastStorageUpgrade := range []bool{false, true} {
+		tree, _ := setupTreeAndMirror(t, 100, skipFastStorageUpgrade)
+		_, _, err := tree.SaveVersion()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		count := 0
+		stopped, err := tree.IterateWithContext(ctx, func(key, value []byte) bool {
+			count++
+			if count == 10 {
+				cancel()
+			}
+			return false
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, stopped)
+		require.Equal(t, 10, count)
+
+		stopped, err = tree.ImmutableTree.IterateWithContext(ctx, func(key, value []byte) bool {
+			return false
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, stopped)
+	}
+}
+
+func TestMutableTree_DeleteVersionsRangeWithContext(t *testing.T) {
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%d", i)), []byte{byte(i)})
+		require.NoError(t, err)
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = tree.DeleteVersionsRangeWithContext(ctx, 1, 4)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, tree.AvailableVersions())
+
+	require.NoError(t, tree.DeleteVersionsRangeWithContext(context.Background(), 1, 3))
+	require.Equal(t, []int{3, 4, 5}, tree.AvailableVersions())
+
+	// The database is consistent with the deletions.
+	tree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4, 5}, tree.AvailableVersions())
+	itree, err := tree.GetImmutable(3)
+	require.NoError(t, err)
+	value, err := itree.Get([]byte("key2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{2}, value)
+}
+
+// countdownContext is a context whose Err returns nil n times, and context.Canceled afterwards.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.n > 0 {
+		ctx.n--
+		return nil
+	}
+	return context.Canceled
+}
+
+func TestMutableTree_DeleteVersionsRangeWithContext_Batches(t *testing.T) {
+	tmpCommitGap := commitGap
+	commitGap = 1
+	defer func() {
+		commitGap = tmpCommitGap
+	}()
+
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		_, err := tree.Set([]byte("key"), []byte{byte(i)})
+		require.NoError(t, err)
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	// A reader of a later version prevents the deletion of the whole range.
+	tree.ndb.incrVersionReaders(3)
+	err = tree.DeleteVersionsRangeWithContext(context.Background(), 1, 5)
+	require.Error(t, err)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, tree.AvailableVersions())
+	tree.ndb.decrVersionReaders(3)
+
+	// The context is checked after the first commit, which deletes the first version.
+	ctx := &countdownContext{Context: context.Background(), n: 1}
+	err = tree.DeleteVersionsRangeWithContext(ctx, 1, 5)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int{2, 3, 4, 5, 6}, tree.AvailableVersions())
+
+	// Deleting the range again deletes the remaining versions.
+	require.NoError(t, tree.DeleteVersionsRangeWithContext(context.Background(), 1, 5))
+	require.Equal(t, []int{5, 6}, tree.AvailableVersions())
+
+	tree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	require.Equal(t, []int{5, 6}, tree.AvailableVersions())
+	itree, err := tree.GetImmutable(5)
+	require.NoError(t, err)
+	value, err := itree.Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{4}, value)
+}
+
+func TestMutableTree_LoadVersionWithContext_InterruptedUpgrade(t *testing.T) {
+	tmpCommitGap := commitGap
+	commitGap = 10
+	defer func() {
+		commitGap = tmpCommitGap
+	}()
+
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, true)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("val%03d", i)))
+		require.NoError(t, err)
+	}
+	_, version, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = tree.LoadVersionWithContext(ctx, version)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The upgrade was not recorded, and is done again on the next load.
+	tree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	isUpgradeable, err := tree.IsUpgradeable()
+	require.NoError(t, err)
+	require.True(t, isUpgradeable)
+	_, err = tree.LoadVersion(version)
+	require.NoError(t, err)
+	isFastCacheEnabled, err := tree.IsFastCacheEnabled()
+	require.NoError(t, err)
+	require.True(t, isFastCacheEnabled)
+
+	count := 0
+	_, err = tree.Iterate(func(key, value []byte) bool {
+		require.Equal(t, fmt.Sprintf("key%03d", count), string(key))
+		require.Equal(t, fmt.Sprintf("val%03d", count), string(value))
+		count++
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+}
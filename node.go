@@ -265,6 +265,11 @@ func (node *Node) _hash() ([]byte, error) {
 // If the tree is empty (i.e. the node is nil), returns the hash of an empty input,
 // to conform with RFC-6962.
 func (node *Node) hashWithCount() ([]byte, int64, error) {
+	return node.hashRecursively(nil)
+}
+
+// hashRecursively is hashWithCount, counting the bytes hashed with meter if it is not nil.
+func (node *Node) hashRecursively(meter Meter) ([]byte, int64, error) {
 	if node == nil {
 		return sha256.New().Sum(nil), 0, nil
 	}
@@ -274,7 +279,7 @@ func (node *Node) hashWithCount() ([]byte, int64, error) {
 
 	h := sha256.New()
 	buf := new(bytes.Buffer)
-	hashCount, err := node.writeHashBytesRecursively(buf)
+	hashCount, err := node.writeHashBytesRecursively(buf, meter)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -283,6 +288,9 @@ func (node *Node) hashWithCount() ([]byte, int64, error) {
 		return nil, 0, err
 	}
 	node.hash = h.Sum(nil)
+	if meter != nil {
+		meter.AddBytesHashed(buf.Len())
+	}
 
 	return node.hash, hashCount + 1, nil
 }
@@ -379,9 +387,9 @@ func (node *Node) writeHashBytes(w io.Writer) error {
 
 // Writes the node's hash to the given io.Writer.
 // This function has the side-effect of calling hashWithCount.
-func (node *Node) writeHashBytesRecursively(w io.Writer) (hashCount int64, err error) {
+func (node *Node) writeHashBytesRecursively(w io.Writer, meter Meter) (hashCount int64, err error) {
 	if node.leftNode != nil {
-		leftHash, leftCount, err := node.leftNode.hashWithCount()
+		leftHash, leftCount, err := node.leftNode.hashRecursively(meter)
 		if err != nil {
 			return 0, err
 		}
@@ -389,7 +397,7 @@ func (node *Node) writeHashBytesRecursively(w io.Writer) (hashCount int64, err e
 		hashCount += leftCount
 	}
 	if node.rightNode != nil {
-		rightHash, rightCount, err := node.rightNode.hashWithCount()
+		rightHash, rightCount, err := node.rightNode.hashRecursively(meter)
 		if err != nil {
 			return 0, err
 		}
@@ -401,6 +409,11 @@ func (node *Node) writeHashBytesRecursively(w io.Writer) (hashCount int64, err e
 	return
 }
 
+// EncodedSize returns the size of the node's storage encoding in bytes.
+func (node *Node) EncodedSize() int {
+	return node.encodedSize()
+}
+
 func (node *Node) encodedSize() int {
 	n := 1 +
 		encoding.EncodeVarintSize(node.size) +
@@ -466,6 +479,7 @@ func (node *Node) writeBytes(w io.Writer) error {
 
 func (node *Node) getLeftNode(t *ImmutableTree) (*Node, error) {
 	if node.leftNode != nil {
+		t.visit(node.leftNode)
 		return node.leftNode, nil
 	}
 	leftNode, err := t.ndb.GetNode(node.leftHash)
@@ -473,11 +487,13 @@ func (node *Node) getLeftNode(t *ImmutableTree) (*Node, error) {
 		return nil, err
 	}
 
+	t.visit(leftNode)
 	return leftNode, nil
 }
 
 func (node *Node) getRightNode(t *ImmutableTree) (*Node, error) {
 	if node.rightNode != nil {
+		t.visit(node.rightNode)
 		return node.rightNode, nil
 	}
 	rightNode, err := t.ndb.GetNode(node.rightHash)
@@ -485,6 +501,7 @@ func (node *Node) getRightNode(t *ImmutableTree) (*Node, error) {
 		return nil, err
 	}
 
+	t.visit(rightNode)
 	return rightNode, nil
 }
 
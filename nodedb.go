@@ -2,6 +2,7 @@ package iavl
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
@@ -15,7 +16,6 @@ import (
 
 	"github.com/cosmos/iavl/cache"
 	"github.com/cosmos/iavl/fastnode"
-	"github.com/cosmos/iavl/internal/logger"
 	"github.com/cosmos/iavl/keyformat"
 )
 
@@ -79,6 +79,15 @@ type nodeDB struct {
 	latestVersion  int64            // Latest version of nodeDB.
 	nodeCache      cache.Cache      // Cache for nodes in the regular tree that consists of key-value pairs at any version.
 	fastNodeCache  cache.Cache      // Cache for nodes in the fast index that represents only key-value pairs at the latest version.
+	recentKeys     *recentKeys      // Most recently used keys, recorded for the cache warm-up. Nil if disabled.
+	metrics        Metrics          // Receives measurements, never nil.
+	logger         Logger           // Never nil.
+	pending        pendingMetrics   // Measurements of the writes in the batch, reported on commit.
+
+	// followsWriter is set when another process writes to the database, so that the fast
+	// storage may be ahead of the loaded versions. Fast nodes are then neither cached nor
+	// assumed to represent the state of the latest loaded version.
+	followsWriter bool
 }
 
 func newNodeDB(db dbm.DB, cacheSize int, opts *Options) *nodeDB {
@@ -93,44 +102,106 @@ func newNodeDB(db dbm.DB, cacheSize int, opts *Options) *nodeDB {
 		storeVersion = []byte(defaultStorageVersionValue)
 	}
 
-	return &nodeDB{
+	ndb := &nodeDB{
 		db:             db,
 		batch:          db.NewBatch(),
 		opts:           *opts,
 		latestVersion:  0, // initially invalid
-		nodeCache:      cache.New(cacheSize),
-		fastNodeCache:  cache.New(fastNodeCacheSize),
 		versionReaders: make(map[int64]uint32, 8),
 		storageVersion: string(storeVersion),
+		metrics:        opts.metrics(),
+		logger:         opts.logger(),
 	}
+	if opts.SharedCache != nil {
+		ndb.nodeCache = opts.SharedCache.Namespace()
+		ndb.fastNodeCache = opts.SharedCache.Namespace()
+	} else {
+		ndb.nodeCache = newCache(opts.CachePolicy, cacheSize, opts.NodeCacheBytes)
+		ndb.fastNodeCache = newCache(opts.CachePolicy, fastNodeCacheSize, opts.FastNodeCacheBytes)
+	}
+	if opts.WarmUp != nil && opts.WarmUp.RecentKeys > 0 {
+		ndb.recentKeys = newRecentKeys(opts.WarmUp.RecentKeys)
+	}
+	return ndb
+}
+
+// touchKey records key as recently used, if tracking is enabled.
+func (ndb *nodeDB) touchKey(key []byte) {
+	if ndb.recentKeys != nil {
+		ndb.recentKeys.touch(key)
+	}
+}
+
+// saveRecentKeys writes the most recently used keys to the database, if tracking is enabled.
+func (ndb *nodeDB) saveRecentKeys() error {
+	if ndb.recentKeys == nil {
+		return nil
+	}
+	bz, err := ndb.recentKeys.encode()
+	if err != nil {
+		return err
+	}
+	// The entry is written directly, leaving any pending batch alone.
+	key := metadataKeyFormat.Key([]byte(recentKeysKey))
+	if ndb.opts.Sync {
+		return ndb.db.SetSync(key, bz)
+	}
+	return ndb.db.Set(key, bz)
+}
+
+// newCache returns a concurrency safe cache using the given policy, bounded by maxBytes
+// if it is positive, and by maxElementCount otherwise. Private caches are not sharded,
+// so that they hold exactly as many nodes as configured. Their lock is only held while
+// accessing the cache, not while loading nodes from the database.
+func newCache(policy CachePolicy, maxElementCount, maxBytes int) cache.Cache {
+	var newShard func(int) cache.Cache
+	switch {
+	case policy == TwoQueueCachePolicy && maxBytes > 0:
+		newShard = cache.NewTwoQueueWithBytesLimit
+	case policy == TwoQueueCachePolicy:
+		newShard = cache.NewTwoQueue
+	case maxBytes > 0:
+		newShard = cache.NewWithBytesLimit
+	default:
+		newShard = cache.New
+	}
+	if maxBytes > 0 {
+		return cache.NewShared(1, maxBytes, newShard)
+	}
+	return cache.NewShared(1, maxElementCount, newShard)
 }
 
 // GetNode gets a node from memory or disk. If it is an inner node, it does not
 // load its children.
+//
+// GetNode does not take the nodeDB lock, so that concurrent readers of
+// ImmutableTrees do not serialize on it, the node cache being concurrency safe.
 func (ndb *nodeDB) GetNode(hash []byte) (*Node, error) {
-	ndb.mtx.Lock()
-	defer ndb.mtx.Unlock()
-
 	if len(hash) == 0 {
 		return nil, ErrNodeMissingHash
 	}
 
 	// Check the cache.
 	if cachedNode := ndb.nodeCache.Get(hash); cachedNode != nil {
-		ndb.opts.Stat.IncCacheHitCnt()
+		ndb.metrics.IncCacheHits(NodeCache)
 		return cachedNode.(*Node), nil
 	}
 
-	ndb.opts.Stat.IncCacheMissCnt()
+	ndb.metrics.IncCacheMisses(NodeCache)
 
 	// Doesn't exist, load.
+	ndb.metrics.IncNodeDiskReads()
 	buf, err := ndb.db.Get(ndb.nodeKey(hash))
 	if err != nil {
-		return nil, fmt.Errorf("can't get node %X: %v", hash, err)
+		return nil, fmt.Errorf("can't get node %X: %w", hash, err)
 	}
 	if buf == nil {
 		return nil, fmt.Errorf("Value missing for hash %x corresponding to nodeKey %x", hash, ndb.nodeKey(hash))
 	}
+	buf, err = decompressBytes(buf)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress node %X: %w", hash, err)
+	}
 
 	node, err := MakeNode(buf)
 	if err != nil {
@@ -149,21 +220,44 @@ func (ndb *nodeDB) GetFastNode(key []byte) (*fastnode.Node, error) {
 		return nil, errors.New("storage version is not fast")
 	}
 
-	ndb.mtx.Lock()
-	defer ndb.mtx.Unlock()
-
 	if len(key) == 0 {
 		return nil, fmt.Errorf("nodeDB.GetFastNode() requires key, len(key) equals 0")
 	}
 
+	if ndb.followsWriter {
+		return ndb.readFastNode(key)
+	}
+
 	if cachedFastNode := ndb.fastNodeCache.Get(key); cachedFastNode != nil {
-		ndb.opts.Stat.IncFastCacheHitCnt()
+		ndb.metrics.IncCacheHits(FastNodeCache)
 		return cachedFastNode.(*fastnode.Node), nil
 	}
 
-	ndb.opts.Stat.IncFastCacheMissCnt()
+	// Unlike nodes, fast nodes are updated in place. Loading them under the lock, and
+	// checking the cache again, ensures that a concurrent save is never overwritten in
+	// the cache by the stale record from the database.
+	ndb.mtx.Lock()
+	defer ndb.mtx.Unlock()
+
+	if cachedFastNode := ndb.fastNodeCache.Get(key); cachedFastNode != nil {
+		ndb.metrics.IncCacheHits(FastNodeCache)
+		return cachedFastNode.(*fastnode.Node), nil
+	}
+
+	ndb.metrics.IncCacheMisses(FastNodeCache)
 
 	// Doesn't exist, load.
+	fastNode, err := ndb.readFastNode(key)
+	if err != nil || fastNode == nil {
+		return nil, err
+	}
+	ndb.fastNodeCache.Add(fastNode)
+	return fastNode, nil
+}
+
+// readFastNode reads a fast node from the database, bypassing the cache. It returns nil if
+// the fast node does not exist.
+func (ndb *nodeDB) readFastNode(key []byte) (*fastnode.Node, error) {
 	buf, err := ndb.db.Get(ndb.fastNodeKey(key))
 	if err != nil {
 		return nil, fmt.Errorf("can't get FastNode %X: %w", key, err)
@@ -171,13 +265,15 @@ func (ndb *nodeDB) GetFastNode(key []byte) (*fastnode.Node, error) {
 	if buf == nil {
 		return nil, nil
 	}
+	buf, err = decompressBytes(buf)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress FastNode %X: %w", key, err)
+	}
 
 	fastNode, err := fastnode.DeserializeNode(key, buf)
 	if err != nil {
 		return nil, fmt.Errorf("error reading FastNode. bytes: %x, error: %w", buf, err)
 	}
-
-	ndb.fastNodeCache.Add(fastNode)
 	return fastNode, nil
 }
 
@@ -200,11 +296,17 @@ func (ndb *nodeDB) SaveNode(node *Node) error {
 	if err := node.writeBytes(&buf); err != nil {
 		return err
 	}
+	bz, err := compressBytes(ndb.opts.Compression, buf.Bytes())
+	if err != nil {
+		return err
+	}
 
-	if err := ndb.batch.Set(ndb.nodeKey(node.hash), buf.Bytes()); err != nil {
+	key := ndb.nodeKey(node.hash)
+	if err := ndb.batch.Set(key, bz); err != nil {
 		return err
 	}
-	logger.Debug("BATCH SAVE %X %p\n", node.hash, node)
+	ndb.pending.nodes++
+	ndb.pending.bytes += len(key) + len(bz)
 	node.persisted = true
 	ndb.nodeCache.Add(node)
 	return nil
@@ -298,10 +400,16 @@ func (ndb *nodeDB) saveFastNodeUnlocked(node *fastnode.Node, shouldAddToCache bo
 	if err := node.WriteBytes(&buf); err != nil {
 		return fmt.Errorf("error while writing fastnode bytes. Err: %w", err)
 	}
+	bz, err := compressBytes(ndb.opts.Compression, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error while compressing fastnode bytes. Err: %w", err)
+	}
 
-	if err := ndb.batch.Set(ndb.fastNodeKey(node.GetKey()), buf.Bytes()); err != nil {
+	key := ndb.fastNodeKey(node.GetKey())
+	if err := ndb.batch.Set(key, bz); err != nil {
 		return fmt.Errorf("error while writing key/val to nodedb batch. Err: %w", err)
 	}
+	ndb.pending.bytes += len(key) + len(bz)
 	if shouldAddToCache {
 		ndb.fastNodeCache.Add(node)
 	}
@@ -490,6 +598,10 @@ func (ndb *nodeDB) DeleteVersionsFrom(version int64) error {
 	// Delete fast node entries
 	err = ndb.traverseFastNodes(func(keyWithPrefix, v []byte) error {
 		key := keyWithPrefix[1:]
+		v, err := decompressBytes(v)
+		if err != nil {
+			return err
+		}
 		fastNode, err := fastnode.DeserializeNode(key, v)
 		if err != nil {
 			return err
@@ -511,41 +623,35 @@ func (ndb *nodeDB) DeleteVersionsFrom(version int64) error {
 	return nil
 }
 
-// DeleteVersionsRange deletes versions from an interval (not inclusive).
-func (ndb *nodeDB) DeleteVersionsRange(fromVersion, toVersion int64) error {
-	if fromVersion >= toVersion {
-		return errors.New("toVersion must be greater than fromVersion")
-	}
-	if toVersion == 0 {
-		return errors.New("toVersion must be greater than 0")
-	}
-
+// DeleteVersionsRange deletes versions from an interval (not inclusive), in ascending order. The
+// batch is committed whenever commitGap orphans have been deleted, between two versions, and ctx
+// is checked after each of these commits: if it is cancelled, the versions committed so far
+// remain deleted and ctx.Err() is returned. The batch of the last versions is left to the caller
+// to commit.
+func (ndb *nodeDB) DeleteVersionsRange(ctx context.Context, fromVersion, toVersion int64) error {
 	ndb.mtx.Lock()
 	defer ndb.mtx.Unlock()
 
-	latest, err := ndb.getLatestVersion()
+	predecessor, err := ndb.checkDeleteVersionsRange(fromVersion, toVersion)
 	if err != nil {
 		return err
 	}
-	if latest < toVersion {
-		return fmt.Errorf("cannot delete latest saved version (%d)", latest)
-	}
-
-	predecessor, err := ndb.getPreviousVersion(fromVersion)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	for v, r := range ndb.versionReaders {
-		if v < toVersion && v > predecessor && r != 0 {
-			return fmt.Errorf("unable to delete version %v with %v active readers", v, r)
-		}
-	}
-
 	// If the predecessor is earlier than the beginning of the lifetime, we can delete the orphan.
 	// Otherwise, we shorten its lifetime, by moving its endpoint to the predecessor version.
+	// Each version is deleted along with its orphans in the same batch, so that a version is
+	// never left without some of its nodes.
+	var deleted, moved int
+	var uncommitted uint64
 	for version := fromVersion; version < toVersion; version++ {
+		if err := ndb.batch.Delete(ndb.rootKey(version)); err != nil {
+			return err
+		}
 		err := ndb.traverseOrphansVersion(version, func(key, hash []byte) error {
+			uncommitted++
 			var from, to int64
 			orphanKeyFormat.Scan(key, &to, &from)
 			if err := ndb.batch.Delete(key); err != nil {
@@ -556,30 +662,64 @@ func (ndb *nodeDB) DeleteVersionsRange(fromVersion, toVersion int64) error {
 					return err
 				}
 				ndb.nodeCache.Remove(hash)
+				ndb.pending.orphansDeleted++
+				deleted++
 			} else {
 				if err := ndb.saveOrphan(hash, from, predecessor); err != nil {
 					return err
 				}
+				moved++
 			}
 			return nil
 		})
 		if err != nil {
 			return err
 		}
+		if uncommitted >= commitGap && version < toVersion-1 {
+			if err := ndb.commit(); err != nil {
+				return err
+			}
+			uncommitted = 0
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 	}
+	ndb.logger.Debug("deleted orphans", "from_version", fromVersion, "to_version", toVersion,
+		"predecessor", predecessor, "deleted", deleted, "moved", moved)
+	return nil
+}
 
-	// Delete the version root entries
-	err = ndb.traverseRange(rootKeyFormat.Key(fromVersion), rootKeyFormat.Key(toVersion), func(k, v []byte) error {
-		if err := ndb.batch.Delete(k); err != nil {
-			return err
-		}
-		return nil
-	})
+// checkDeleteVersionsRange checks that the versions from fromVersion to toVersion (not
+// inclusive) can be deleted, and returns the version preceding them. The caller must hold
+// ndb.mtx.
+func (ndb *nodeDB) checkDeleteVersionsRange(fromVersion, toVersion int64) (int64, error) {
+	if fromVersion >= toVersion {
+		return 0, errors.New("toVersion must be greater than fromVersion")
+	}
+	if toVersion == 0 {
+		return 0, errors.New("toVersion must be greater than 0")
+	}
 
+	latest, err := ndb.getLatestVersion()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	if latest < toVersion {
+		return 0, fmt.Errorf("cannot delete latest saved version (%d)", latest)
+	}
+
+	predecessor, err := ndb.getPreviousVersion(fromVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	for v, r := range ndb.versionReaders {
+		if v < toVersion && v > predecessor && r != 0 {
+			return 0, fmt.Errorf("unable to delete version %v with %v active readers", v, r)
+		}
+	}
+	return predecessor, nil
 }
 
 func (ndb *nodeDB) DeleteFastNode(key []byte) error {
@@ -639,12 +779,13 @@ func (ndb *nodeDB) SaveOrphans(version int64, orphans map[string]int64) error {
 	}
 
 	for hash, fromVersion := range orphans {
-		logger.Debug("SAVEORPHAN %v-%v %X\n", fromVersion, toVersion, hash)
 		err := ndb.saveOrphan([]byte(hash), fromVersion, toVersion)
 		if err != nil {
 			return err
 		}
 	}
+	ndb.pending.orphansCreated += len(orphans)
+	ndb.logger.Debug("saved orphans", "version", version, "to_version", toVersion, "count", len(orphans))
 	return nil
 }
 
@@ -689,13 +830,14 @@ func (ndb *nodeDB) deleteOrphans(version int64) error {
 		// can delete the orphan.  Otherwise, we shorten its lifetime, by
 		// moving its endpoint to the previous version.
 		if predecessor < fromVersion || fromVersion == toVersion {
-			logger.Debug("DELETE predecessor:%v fromVersion:%v toVersion:%v %X\n", predecessor, fromVersion, toVersion, hash)
+			ndb.logger.Debug("deleting orphan", "hash", hash, "from_version", fromVersion, "to_version", toVersion, "predecessor", predecessor)
 			if err := ndb.batch.Delete(ndb.nodeKey(hash)); err != nil {
 				return err
 			}
 			ndb.nodeCache.Remove(hash)
+			ndb.pending.orphansDeleted++
 		} else {
-			logger.Debug("MOVE predecessor:%v fromVersion:%v toVersion:%v %X\n", predecessor, fromVersion, toVersion, hash)
+			ndb.logger.Debug("moving orphan", "hash", hash, "from_version", fromVersion, "to_version", toVersion, "predecessor", predecessor)
 			err := ndb.saveOrphan(hash, fromVersion, predecessor)
 			if err != nil {
 				return err
@@ -869,7 +1011,11 @@ func (ndb *nodeDB) getFastIterator(start, end []byte, ascending bool) (dbm.Itera
 func (ndb *nodeDB) Commit() error {
 	ndb.mtx.Lock()
 	defer ndb.mtx.Unlock()
+	return ndb.commit()
+}
 
+// commit writes the batch and starts a new one. The caller must hold ndb.mtx.
+func (ndb *nodeDB) commit() error {
 	var err error
 	if ndb.opts.Sync {
 		err = ndb.batch.WriteSync()
@@ -882,10 +1028,25 @@ func (ndb *nodeDB) Commit() error {
 
 	ndb.batch.Close()
 	ndb.batch = ndb.db.NewBatch()
+	ndb.reportPending()
 
 	return nil
 }
 
+// reportPending reports the measurements of the committed writes, and the cache sizes.
+func (ndb *nodeDB) reportPending() {
+	ndb.metrics.ObserveCommit(ndb.pending.nodes, ndb.pending.bytes)
+	if ndb.pending.orphansCreated > 0 {
+		ndb.metrics.AddOrphansCreated(ndb.pending.orphansCreated)
+	}
+	if ndb.pending.orphansDeleted > 0 {
+		ndb.metrics.AddOrphansDeleted(ndb.pending.orphansDeleted)
+	}
+	ndb.metrics.SetCacheSize(NodeCache, ndb.nodeCache.Len())
+	ndb.metrics.SetCacheSize(FastNodeCache, ndb.fastNodeCache.Len())
+	ndb.pending = pendingMetrics{}
+}
+
 func (ndb *nodeDB) HasRoot(version int64) (bool, error) {
 	return ndb.db.Has(ndb.rootKey(version))
 }
@@ -1025,6 +1186,10 @@ func (ndb *nodeDB) traverseNodes(fn func(hash []byte, node *Node) error) error {
 	nodes := []*Node{}
 
 	err := ndb.traversePrefix(nodeKeyFormat.Key(), func(key, value []byte) error {
+		value, err := decompressBytes(value)
+		if err != nil {
+			return err
+		}
 		node, err := MakeNode(value)
 		if err != nil {
 			return err
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"math/rand"
 	"strconv"
+	"sync"
 	"testing"
 
 	db "github.com/cosmos/cosmos-db"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cosmos/iavl/cache"
 	"github.com/cosmos/iavl/mock"
 )
 
@@ -261,6 +263,114 @@ func TestIsFastStorageEnabled_False(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestNodeDB_CacheBytesLimit(t *testing.T) {
+	const nodeCacheBytes, fastNodeCacheBytes = 4096, 2048
+
+	for _, policy := range []CachePolicy{LRUCachePolicy, TwoQueueCachePolicy} {
+		opts := DefaultOptions()
+		opts.NodeCacheBytes = nodeCacheBytes
+		opts.FastNodeCacheBytes = fastNodeCacheBytes
+		opts.CachePolicy = policy
+		tree, err := NewMutableTreeWithOpts(db.NewMemDB(), 1000000, &opts, false)
+		require.NoError(t, err)
+
+		for i := 0; i < 500; i++ {
+			key := []byte(strconv.Itoa(i))
+			_, err = tree.Set(key, key)
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+
+		for i := 0; i < 500; i++ {
+			key := []byte(strconv.Itoa(i))
+			value, err := tree.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, key, value)
+			_, value, err = tree.GetWithIndex(key)
+			require.NoError(t, err)
+			require.Equal(t, key, value)
+		}
+
+		// Every node is keyed by its hash, so each one accounts for more than hashSize bytes,
+		// and every fast node for more than a single byte key and value.
+		require.NotZero(t, tree.ndb.nodeCache.Len())
+		require.Less(t, tree.ndb.nodeCache.Len(), nodeCacheBytes/hashSize)
+		require.NotZero(t, tree.ndb.fastNodeCache.Len())
+		require.Less(t, tree.ndb.fastNodeCache.Len(), fastNodeCacheBytes/3)
+	}
+}
+
+func TestNodeDB_SharedCache(t *testing.T) {
+	shared := cache.NewShared(4, 1000, cache.New)
+	opts := DefaultOptions()
+	opts.SharedCache = shared
+
+	// Both trees store the same keys with different values.
+	trees := make([]*MutableTree, 2)
+	for i := range trees {
+		tree, err := NewMutableTreeWithOpts(db.NewMemDB(), 0, &opts, false)
+		require.NoError(t, err)
+		for j := 0; j < 100; j++ {
+			_, err = tree.Set([]byte(strconv.Itoa(j)), []byte(strconv.Itoa(i*1000+j)))
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+		trees[i] = tree
+	}
+
+	require.Positive(t, shared.Len())
+	require.LessOrEqual(t, shared.Len(), 1000)
+	for i, tree := range trees {
+		for j := 0; j < 100; j++ {
+			key := []byte(strconv.Itoa(j))
+			require.True(t, tree.ndb.fastNodeCache.Has(key))
+			value, err := tree.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, []byte(strconv.Itoa(i*1000+j)), value)
+		}
+	}
+}
+
+func TestNodeDB_ConcurrentReaders(t *testing.T) {
+	for _, shared := range []bool{false, true} {
+		opts := DefaultOptions()
+		if shared {
+			opts.SharedCache = cache.NewShared(4, 100, cache.NewTwoQueue)
+		}
+		// A small cache makes the readers load nodes from the database.
+		tree, err := NewMutableTreeWithOpts(db.NewMemDB(), 100, &opts, false)
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			_, err = tree.Set([]byte(strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+			require.NoError(t, err)
+		}
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+		itree, err := tree.GetImmutable(tree.Version())
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := g; i < 1000; i += 3 {
+					key := []byte(strconv.Itoa(i))
+					value, err := itree.Get(key)
+					require.NoError(t, err)
+					require.Equal(t, key, value)
+					_, value, err = itree.GetWithIndex(key)
+					require.NoError(t, err)
+					require.Equal(t, key, value)
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
+
 func makeHashes(b *testing.B, seed int64) [][]byte {
 	b.StopTimer()
 	rnd := rand.NewSource(seed)
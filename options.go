@@ -1,6 +1,11 @@
 package iavl
 
-import "sync/atomic"
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/iavl/cache"
+)
 
 // Statisc about db runtime state
 type Statistics struct {
@@ -61,6 +66,44 @@ func (stat *Statistics) GetFastCacheMissCnt() uint64 {
 	return atomic.LoadUint64(&stat.fastCacheMissCnt)
 }
 
+var _ Metrics = (*Statistics)(nil)
+
+// ObserveLatency implements Metrics, and is a no-op.
+func (stat *Statistics) ObserveLatency(Operation, time.Duration) {}
+
+// ObserveCommit implements Metrics, and is a no-op.
+func (stat *Statistics) ObserveCommit(int, int) {}
+
+// AddOrphansCreated implements Metrics, and is a no-op.
+func (stat *Statistics) AddOrphansCreated(int) {}
+
+// AddOrphansDeleted implements Metrics, and is a no-op.
+func (stat *Statistics) AddOrphansDeleted(int) {}
+
+// IncNodeDiskReads implements Metrics, and is a no-op.
+func (stat *Statistics) IncNodeDiskReads() {}
+
+// IncCacheHits implements Metrics.
+func (stat *Statistics) IncCacheHits(cache CacheKind) {
+	if cache == FastNodeCache {
+		stat.IncFastCacheHitCnt()
+	} else {
+		stat.IncCacheHitCnt()
+	}
+}
+
+// IncCacheMisses implements Metrics.
+func (stat *Statistics) IncCacheMisses(cache CacheKind) {
+	if cache == FastNodeCache {
+		stat.IncFastCacheMissCnt()
+	} else {
+		stat.IncCacheMissCnt()
+	}
+}
+
+// SetCacheSize implements Metrics, and is a no-op.
+func (stat *Statistics) SetCacheSize(CacheKind, int) {}
+
 func (stat *Statistics) Reset() {
 	atomic.StoreUint64(&stat.cacheHitCnt, 0)
 	atomic.StoreUint64(&stat.cacheMissCnt, 0)
@@ -80,7 +123,70 @@ type Options struct {
 	InitialVersion uint64
 
 	// When Stat is not nil, statistical logic needs to be executed
+	//
+	// Deprecated: use Metrics, which Statistics implements. Stat is ignored if Metrics is set.
 	Stat *Statistics
+
+	// Metrics, if not nil, receives measurements of the tree operations, writes and caches.
+	Metrics Metrics
+
+	// Logger, if not nil, receives the logs of the tree.
+	Logger Logger
+
+	// Compression selects the codec used to compress nodes and fast nodes written to the
+	// database. Records are tagged with their codec, so the setting can be changed for an
+	// existing store: old records remain readable and new ones use the new codec.
+	Compression Compression
+
+	// NodeCacheBytes bounds the node cache by the total size of the cached nodes in bytes.
+	// If zero, the node cache is bounded by the number of nodes passed as cacheSize instead.
+	NodeCacheBytes int
+
+	// FastNodeCacheBytes bounds the fast node cache by the total size of the cached fast
+	// nodes in bytes. If zero, the fast node cache holds a fixed number of nodes.
+	FastNodeCacheBytes int
+
+	// CachePolicy selects the eviction policy of the node and fast node caches.
+	CachePolicy CachePolicy
+
+	// SharedCache, if not nil, is used for the node and fast node caches of the tree instead
+	// of private caches. Each tree gets its own namespaces in it, and all trees sharing it
+	// draw from its single budget. The cache size and cache options above are then ignored.
+	SharedCache *cache.Shared
+
+	// WarmUp, if not nil, enables the warm-up of the caches after the tree is loaded.
+	WarmUp *WarmUpOptions
+}
+
+// CachePolicy identifies the eviction policy of the node caches.
+type CachePolicy byte
+
+const (
+	// LRUCachePolicy evicts the least recently used nodes. This is the default.
+	LRUCachePolicy CachePolicy = iota
+	// TwoQueueCachePolicy uses the scan resistant 2Q policy, which keeps frequently used
+	// nodes cached while nodes are iterated over or exported.
+	TwoQueueCachePolicy
+)
+
+// metrics returns the Metrics to report to, which are never nil.
+func (opts *Options) metrics() Metrics {
+	switch {
+	case opts.Metrics != nil:
+		return opts.Metrics
+	case opts.Stat != nil:
+		return opts.Stat
+	default:
+		return NopMetrics{}
+	}
+}
+
+// logger returns the Logger to log to, which is never nil.
+func (opts *Options) logger() Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return NewNopLogger()
 }
 
 // DefaultOptions returns the default options for IAVL.
@@ -0,0 +1,108 @@
+package iavl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// ErrNotWitnessed is returned when a PartialTree accesses a node of a subtree pruned from its
+// witness.
+var ErrNotWitnessed = errors.New("node is not witnessed")
+
+/*
+PartialTree is a tree holding only the nodes of a Witness, with no database, e.g. to re-execute
+blocks statelessly or to check fraud proofs. It reads and changes keys with the code of
+MutableTree, rebalancing and hashing included, so that replaying the reads and changes the witness
+was recorded for derives the root hashes the recording tree saved.
+
+Accessing a key in a subtree pruned from the witness fails with an error wrapping ErrNotWitnessed,
+and leaves the tree unchanged. A PartialTree is not safe for concurrent use.
+*/
+type PartialTree struct {
+	tree *MutableTree
+}
+
+// NewPartialTree returns a PartialTree holding the nodes of witness, which must be a valid witness
+// of the trusted rootHash: see Witness.Verify.
+func NewPartialTree(witness *Witness, rootHash []byte) (*PartialTree, error) {
+	if witness == nil {
+		return nil, fmt.Errorf("%w: nil witness", ErrInvalidProof)
+	}
+	if err := witness.Verify(rootHash); err != nil {
+		return nil, err
+	}
+	db, err := newNodesDB(witness.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := newReplayTree(&witnessDB{readOnlyDB: &readOnlyDB{DB: db}}, rootHash, witness.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &PartialTree{tree: tree}, nil
+}
+
+// Version returns the latest version of the tree: the version of the witness, until SaveVersion
+// is called.
+func (t *PartialTree) Version() int64 {
+	return t.tree.version
+}
+
+// Get returns the value of key, or nil if it does not exist.
+func (t *PartialTree) Get(key []byte) ([]byte, error) {
+	return t.tree.Get(key)
+}
+
+// Has returns whether key exists.
+func (t *PartialTree) Has(key []byte) (bool, error) {
+	return t.tree.Has(key)
+}
+
+// Set sets key to value, returning whether an existing value was updated.
+func (t *PartialTree) Set(key, value []byte) (updated bool, err error) {
+	return t.tree.Set(key, value)
+}
+
+// Remove removes key, returning its value and whether it existed.
+func (t *PartialTree) Remove(key []byte) ([]byte, bool, error) {
+	return t.tree.Remove(key)
+}
+
+// Iterator returns an iterator over the keys of the tree between start and end. If it reaches a
+// node pruned from the witness, it becomes invalid and its Error method returns the error.
+func (t *PartialTree) Iterator(start, end []byte, ascending bool) (dbm.Iterator, error) {
+	return t.tree.Iterator(start, end, ascending)
+}
+
+// WorkingHash returns the root hash of the tree, changes included.
+func (t *PartialTree) WorkingHash() ([]byte, error) {
+	return t.tree.WorkingHash()
+}
+
+// SaveVersion returns the root hash and version MutableTree.SaveVersion would return for the
+// changes made since the last version, and starts the next version. Nothing is persisted.
+func (t *PartialTree) SaveVersion() ([]byte, int64, error) {
+	hash, err := t.tree.WorkingHash()
+	if err != nil {
+		return nil, 0, err
+	}
+	t.tree.version++
+	return hash, t.tree.version, nil
+}
+
+// witnessDB is a read-only database holding the nodes of a witness. Reading a node it does not
+// hold fails with ErrNotWitnessed.
+type witnessDB struct {
+	*readOnlyDB
+}
+
+func (db *witnessDB) Get(key []byte) ([]byte, error) {
+	value, err := db.readOnlyDB.Get(key)
+	if err == nil && value == nil && bytes.HasPrefix(key, []byte(nodeKeyFormat.Prefix())) {
+		return nil, fmt.Errorf("%w: %X", ErrNotWitnessed, key[len(nodeKeyFormat.Prefix()):])
+	}
+	return value, err
+}
@@ -0,0 +1,111 @@
+package iavl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialTree(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+		require.NoError(t, err)
+	}
+	root, _, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	// Record the witness of two blocks, saving each of them.
+	require.NoError(t, tree.StartWitness())
+	var reads [][][]byte
+	var hashes [][]byte
+	for seed := int64(1); seed <= 2; seed++ {
+		reads = append(reads, executeBlock(t, tree, rand.New(rand.NewSource(seed))))
+		hash, _, err := tree.SaveVersion()
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	witness, err := tree.StopWitness()
+	require.NoError(t, err)
+
+	_, err = NewPartialTree(witness, hashes[0])
+	require.ErrorIs(t, err, ErrInvalidRoot)
+	partial, err := NewPartialTree(witness, root)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), partial.Version())
+
+	// Replaying the blocks reads the same values and saves the same root hashes.
+	for i, seed := range []int64{1, 2} {
+		require.Equal(t, reads[i], executeBlock(t, partial, rand.New(rand.NewSource(seed))))
+		hash, version, err := partial.SaveVersion()
+		require.NoError(t, err)
+		require.Equal(t, hashes[i], hash)
+		require.Equal(t, int64(i+2), version)
+	}
+
+	// Keys in pruned subtrees cannot be accessed, and the tree is left unchanged.
+	hash, err := partial.WorkingHash()
+	require.NoError(t, err)
+	var key []byte
+	for i := 0; i < 1000 && key == nil; i++ {
+		if _, err := partial.Get([]byte(fmt.Sprintf("key%04d", i))); err != nil {
+			require.ErrorIs(t, err, ErrNotWitnessed)
+			key = []byte(fmt.Sprintf("key%04d", i))
+		}
+	}
+	require.NotNil(t, key)
+	_, err = partial.Has(key)
+	require.ErrorIs(t, err, ErrNotWitnessed)
+	_, err = partial.Set(key, []byte("new"))
+	require.ErrorIs(t, err, ErrNotWitnessed)
+	_, _, err = partial.Remove(key)
+	require.ErrorIs(t, err, ErrNotWitnessed)
+	itr, err := partial.Iterator(key, nil, true)
+	require.NoError(t, err)
+	require.False(t, itr.Valid())
+	require.ErrorIs(t, itr.Error(), ErrNotWitnessed)
+	workingHash, err := partial.WorkingHash()
+	require.NoError(t, err)
+	require.Equal(t, hash, workingHash)
+}
+
+func TestPartialTree_Fraud(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+		require.NoError(t, err)
+	}
+	root, _, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	require.NoError(t, tree.StartWitness())
+	_, err = tree.Set([]byte("key050"), []byte("changed"))
+	require.NoError(t, err)
+	_, _, err = tree.Remove([]byte("key020"))
+	require.NoError(t, err)
+	witness, err := tree.StopWitness()
+	require.NoError(t, err)
+	hash, version, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	// The honest changes derive the saved root hash, while other changes do not.
+	for _, value := range [][]byte{[]byte("changed"), []byte("fraud")} {
+		partial, err := NewPartialTree(witness, root)
+		require.NoError(t, err)
+		_, err = partial.Set([]byte("key050"), value)
+		require.NoError(t, err)
+		removed, ok, err := partial.Remove([]byte("key020"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, []byte("value"), removed)
+		partialHash, partialVersion, err := partial.SaveVersion()
+		require.NoError(t, err)
+		require.Equal(t, version, partialVersion)
+		require.Equal(t, string(value) == "changed", string(hash) == string(partialHash))
+	}
+}
@@ -2,13 +2,10 @@ package iavl
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"errors"
-	"fmt"
 	"sync"
 
-	hexbytes "github.com/cosmos/iavl/internal/bytes"
-	"github.com/cosmos/iavl/internal/encoding"
+	"github.com/cosmos/iavl/verify"
 )
 
 var bufPool = &sync.Pool{
@@ -19,147 +16,23 @@ var bufPool = &sync.Pool{
 
 var (
 	// ErrInvalidProof is returned by Verify when a proof cannot be validated.
-	ErrInvalidProof = fmt.Errorf("invalid proof")
+	ErrInvalidProof = verify.ErrInvalidProof
 
 	// ErrInvalidInputs is returned when the inputs passed to the function are invalid.
-	ErrInvalidInputs = fmt.Errorf("invalid inputs")
+	ErrInvalidInputs = verify.ErrInvalidInputs
 
 	// ErrInvalidRoot is returned when the root passed in does not match the proof's.
-	ErrInvalidRoot = fmt.Errorf("invalid root")
+	ErrInvalidRoot = verify.ErrInvalidRoot
 )
 
-//----------------------------------------
-// ProofInnerNode
-// Contract: Left and Right can never both be set. Will result in a empty `[]` roothash
-
-type ProofInnerNode struct {
-	Height  int8   `json:"height"`
-	Size    int64  `json:"size"`
-	Version int64  `json:"version"`
-	Left    []byte `json:"left"`
-	Right   []byte `json:"right"`
-}
-
-func (pin ProofInnerNode) String() string {
-	return pin.stringIndented("")
-}
-
-func (pin ProofInnerNode) stringIndented(indent string) string {
-	return fmt.Sprintf(`ProofInnerNode{
-%s  Height:  %v
-%s  Size:    %v
-%s  Version: %v
-%s  Left:    %X
-%s  Right:   %X
-%s}`,
-		indent, pin.Height,
-		indent, pin.Size,
-		indent, pin.Version,
-		indent, pin.Left,
-		indent, pin.Right,
-		indent)
-}
-
-func (pin ProofInnerNode) Hash(childHash []byte) ([]byte, error) {
-	hasher := sha256.New()
-
-	buf := bufPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	defer bufPool.Put(buf)
-
-	err := encoding.EncodeVarint(buf, int64(pin.Height))
-	if err == nil {
-		err = encoding.EncodeVarint(buf, pin.Size)
-	}
-	if err == nil {
-		err = encoding.EncodeVarint(buf, pin.Version)
-	}
-
-	if len(pin.Left) > 0 && len(pin.Right) > 0 {
-		return nil, errors.New("both left and right child hashes are set")
-	}
-
-	if len(pin.Left) == 0 {
-		if err == nil {
-			err = encoding.EncodeBytes(buf, childHash)
-		}
-		if err == nil {
-			err = encoding.EncodeBytes(buf, pin.Right)
-		}
-	} else {
-		if err == nil {
-			err = encoding.EncodeBytes(buf, pin.Left)
-		}
-		if err == nil {
-			err = encoding.EncodeBytes(buf, childHash)
-		}
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash ProofInnerNode: %v", err)
-	}
-
-	_, err = hasher.Write(buf.Bytes())
-	if err != nil {
-		return nil, err
-	}
-	return hasher.Sum(nil), nil
-}
-
-//----------------------------------------
-
-type ProofLeafNode struct {
-	Key       hexbytes.HexBytes `json:"key"`
-	ValueHash hexbytes.HexBytes `json:"value"`
-	Version   int64             `json:"version"`
-}
-
-func (pln ProofLeafNode) String() string {
-	return pln.stringIndented("")
-}
-
-func (pln ProofLeafNode) stringIndented(indent string) string {
-	return fmt.Sprintf(`ProofLeafNode{
-%s  Key:       %v
-%s  ValueHash: %X
-%s  Version:   %v
-%s}`,
-		indent, pln.Key,
-		indent, pln.ValueHash,
-		indent, pln.Version,
-		indent)
-}
-
-func (pln ProofLeafNode) Hash() ([]byte, error) {
-	hasher := sha256.New()
-
-	buf := bufPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	defer bufPool.Put(buf)
-
-	err := encoding.EncodeVarint(buf, 0)
-	if err == nil {
-		err = encoding.EncodeVarint(buf, 1)
-	}
-	if err == nil {
-		err = encoding.EncodeVarint(buf, pln.Version)
-	}
-	if err == nil {
-		err = encoding.EncodeBytes(buf, pln.Key)
-	}
-	if err == nil {
-		err = encoding.EncodeBytes(buf, pln.ValueHash)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash ProofLeafNode: %v", err)
-	}
-	_, err = hasher.Write(buf.Bytes())
-	if err != nil {
-		return nil, err
-	}
-
-	return hasher.Sum(nil), nil
-}
+// The proof types are defined in the verify package, which light clients can use to verify
+// proofs without depending on the rest of this package.
+type (
+	ProofInnerNode = verify.ProofInnerNode
+	ProofLeafNode  = verify.ProofLeafNode
+	PathToLeaf     = verify.PathToLeaf
+	RangeProof     = verify.RangeProof
+)
 
 //----------------------------------------
 
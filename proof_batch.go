@@ -0,0 +1,215 @@
+package iavl
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	ics23 "github.com/confio/ics23/go"
+)
+
+/*
+GetBatchProof will produce a CommitmentProof holding an ics23.BatchProof, with an existence proof
+for each of the given keys in the tree, and a non-existence proof for each of the others.
+
+The leaves to prove, i.e. the keys in the tree and the neighbors of the others, are first looked
+up key by key. Their existence proofs are then built in a single walk down the tree, visiting each
+node on their paths once, and the inner ops of the nodes shared by several paths are shared by
+their proofs.
+*/
+func (t *ImmutableTree) GetBatchProof(keys [][]byte) (*ics23.CommitmentProof, error) {
+	if t.root == nil {
+		return nil, fmt.Errorf("cannot generate the proof with nil root")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("cannot generate the proof of no keys")
+	}
+	if _, err := t.Hash(); err != nil {
+		return nil, err
+	}
+
+	keys = sortedUniqueKeys(keys)
+
+	// Find the leaves to prove: the keys in the tree, and the neighbors of the others.
+	type batchKey struct {
+		exists      bool
+		left, right []byte // The neighbors of a key not in the tree, if any.
+	}
+	batchKeys := make([]batchKey, len(keys))
+	leaves := make([][]byte, 0, len(keys))
+	for i, key := range keys {
+		idx, val, err := t.GetWithIndex(key)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			batchKeys[i].exists = true
+			leaves = append(leaves, key)
+			continue
+		}
+		if idx >= 1 {
+			batchKeys[i].left, _, err = t.GetByIndex(idx - 1)
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, batchKeys[i].left)
+		}
+		batchKeys[i].right, _, err = t.GetByIndex(idx)
+		if err != nil {
+			return nil, err
+		}
+		if batchKeys[i].right != nil {
+			leaves = append(leaves, batchKeys[i].right)
+		}
+	}
+
+	exists := make(map[string]*ics23.ExistenceProof, len(leaves))
+	if err := t.root.batchExistenceProofs(t, sortedUniqueKeys(leaves), exists); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*ics23.BatchEntry, len(keys))
+	for i, key := range keys {
+		if batchKeys[i].exists {
+			entries[i] = &ics23.BatchEntry{
+				Proof: &ics23.BatchEntry_Exist{Exist: exists[string(key)]},
+			}
+			continue
+		}
+		nonexist := &ics23.NonExistenceProof{Key: key}
+		if batchKeys[i].left != nil {
+			nonexist.Left = exists[string(batchKeys[i].left)]
+		}
+		if batchKeys[i].right != nil {
+			nonexist.Right = exists[string(batchKeys[i].right)]
+		}
+		entries[i] = &ics23.BatchEntry{
+			Proof: &ics23.BatchEntry_Nonexist{Nonexist: nonexist},
+		}
+	}
+
+	proof := &ics23.CommitmentProof{
+		Proof: &ics23.CommitmentProof_Batch{
+			Batch: &ics23.BatchProof{Entries: entries},
+		},
+	}
+	return proof, nil
+}
+
+// GetCompressedBatchProof is GetBatchProof, with the proof compressed into an
+// ics23.CompressedBatchProof, which stores the inner ops shared by several entries only once.
+func (t *ImmutableTree) GetCompressedBatchProof(keys [][]byte) (*ics23.CommitmentProof, error) {
+	proof, err := t.GetBatchProof(keys)
+	if err != nil {
+		return nil, err
+	}
+	return ics23.Compress(proof), nil
+}
+
+// VerifyBatchProof returns true iff proof, be it compressed or not, proves the existence of each
+// of the given keys in the tree with its value, and the non-existence of each of the others.
+func (t *ImmutableTree) VerifyBatchProof(proof *ics23.CommitmentProof, keys [][]byte) (bool, error) {
+	root, err := t.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	items := make(map[string][]byte, len(keys))
+	var missing [][]byte
+	for _, key := range keys {
+		_, val, err := t.GetWithIndex(key) // Not Get, as for VerifyMembership.
+		if err != nil {
+			return false, err
+		}
+		if val != nil {
+			items[string(key)] = val
+		} else {
+			missing = append(missing, key)
+		}
+	}
+
+	return ics23.BatchVerifyMembership(ics23.IavlSpec, root, proof, items) &&
+		ics23.BatchVerifyNonMembership(ics23.IavlSpec, root, proof, missing), nil
+}
+
+// batchExistenceProofs walks down from node to the leaves with the given sorted keys, which must
+// all be in the subtree of node, and sets their existence proofs in proofs. The paths are built
+// from the leaves up, and the inner op of each node is shared by all paths going through it.
+func (node *Node) batchExistenceProofs(t *ImmutableTree, keys [][]byte, proofs map[string]*ics23.ExistenceProof) error {
+	if node.isLeaf() {
+		for _, key := range keys {
+			if !bytes.Equal(node.key, key) {
+				return fmt.Errorf("key %X does not exist", key)
+			}
+		}
+		if len(keys) != 1 {
+			return fmt.Errorf("expected a single key for leaf %X, got %d", node.key, len(keys))
+		}
+		proofs[string(node.key)] = &ics23.ExistenceProof{
+			Key:   node.key,
+			Value: node.value,
+			Leaf:  convertLeafOp(node.version),
+		}
+		return nil
+	}
+
+	leftNode, err := node.getLeftNode(t)
+	if err != nil {
+		return err
+	}
+	rightNode, err := node.getRightNode(t)
+	if err != nil {
+		return err
+	}
+
+	split := sort.Search(len(keys), func(i int) bool {
+		return bytes.Compare(keys[i], node.key) >= 0
+	})
+	if split > 0 {
+		if err := leftNode.batchExistenceProofs(t, keys[:split], proofs); err != nil {
+			return err
+		}
+		op := convertInnerOp(ProofInnerNode{
+			Height:  node.subtreeHeight,
+			Size:    node.size,
+			Version: node.version,
+			Right:   rightNode.hash,
+		})
+		for _, key := range keys[:split] {
+			exist := proofs[string(key)]
+			exist.Path = append(exist.Path, op)
+		}
+	}
+	if split < len(keys) {
+		if err := rightNode.batchExistenceProofs(t, keys[split:], proofs); err != nil {
+			return err
+		}
+		op := convertInnerOp(ProofInnerNode{
+			Height:  node.subtreeHeight,
+			Size:    node.size,
+			Version: node.version,
+			Left:    leftNode.hash,
+		})
+		for _, key := range keys[split:] {
+			exist := proofs[string(key)]
+			exist.Path = append(exist.Path, op)
+		}
+	}
+	return nil
+}
+
+// sortedUniqueKeys returns a sorted copy of keys, without duplicates.
+func sortedUniqueKeys(keys [][]byte) [][]byte {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	unique := sorted[:0]
+	for i, key := range sorted {
+		if i == 0 || !bytes.Equal(key, sorted[i-1]) {
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,144 @@
+package iavl
+
+import (
+	"testing"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBatchProof(t *testing.T) {
+	tree, allkeys, err := BuildTree(1000, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	var keys [][]byte
+	for i := 0; i < len(allkeys); i += 7 {
+		keys = append(keys, allkeys[i])
+	}
+	keys = append(keys, allkeys[0], allkeys[len(allkeys)-1], allkeys[7]) // Edges and a duplicate.
+	missing := [][]byte{GetNonKey(allkeys, Left), GetNonKey(allkeys, Right)}
+	for i := 0; i < 20; i++ {
+		missing = append(missing, GetNonKey(allkeys, Middle))
+	}
+	keys = append(keys, missing...)
+
+	proof, err := tree.GetBatchProof(keys)
+	require.NoError(t, err)
+	require.NotNil(t, proof.GetBatch())
+	require.Len(t, proof.GetBatch().Entries, len(sortedUniqueKeys(keys)))
+
+	valid, err := tree.VerifyBatchProof(proof, keys)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// The entries match the proofs of single keys.
+	batchExists := make(map[string]*ics23.ExistenceProof)
+	for _, entry := range proof.GetBatch().Entries {
+		if exist := entry.GetExist(); exist != nil {
+			batchExists[string(exist.Key)] = exist
+		}
+	}
+	singleSize := 0
+	for _, key := range sortedUniqueKeys(keys) {
+		single, err := tree.GetProof(key)
+		require.NoError(t, err)
+		singleSize += single.Size()
+		if exist := single.GetExist(); exist != nil {
+			val, err := tree.Get(key)
+			require.NoError(t, err)
+			require.True(t, ics23.VerifyMembership(ics23.IavlSpec, root, proof, key, val))
+			require.Equal(t, exist.String(), batchExists[string(key)].String())
+		} else {
+			require.True(t, ics23.VerifyNonMembership(ics23.IavlSpec, root, proof, key))
+		}
+	}
+
+	compressed, err := tree.GetCompressedBatchProof(keys)
+	require.NoError(t, err)
+	require.NotNil(t, compressed.GetCompressed())
+	valid, err = tree.VerifyBatchProof(compressed, keys)
+	require.NoError(t, err)
+	require.True(t, valid)
+	require.Less(t, compressed.Size(), proof.Size())
+	require.Less(t, compressed.Size(), singleSize/2)
+
+	// Keys not covered by the proof are rejected.
+	valid, err = tree.VerifyBatchProof(proof, append(keys, allkeys[1]))
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// So are proofs of another tree.
+	_, err = tree.Set(allkeys[7], []byte("changed"))
+	require.NoError(t, err)
+	valid, err = tree.VerifyBatchProof(proof, keys)
+	require.NoError(t, err)
+	require.False(t, valid)
+}
+
+func TestGetBatchProof_SmallTrees(t *testing.T) {
+	tree := setupMutableTree(t, false)
+	_, err := tree.GetBatchProof([][]byte{[]byte("a")})
+	require.Error(t, err)
+
+	_, err = tree.Set([]byte("b"), []byte("1"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	_, err = tree.GetBatchProof(nil)
+	require.Error(t, err)
+	_, err = tree.GetBatchProof([][]byte{})
+	require.Error(t, err)
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	proof, err := tree.GetBatchProof(keys)
+	require.NoError(t, err)
+	valid, err := tree.VerifyBatchProof(proof, keys)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	_, err = tree.Set([]byte("d"), []byte("2"))
+	require.NoError(t, err)
+	proof, err = tree.GetCompressedBatchProof(keys)
+	require.NoError(t, err)
+	valid, err = tree.VerifyBatchProof(proof, keys)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func BenchmarkGetBatchProof(b *testing.B) {
+	tree, allkeys, err := BuildTree(100000, 0)
+	require.NoError(b, err)
+	_, err = tree.WorkingHash()
+	require.NoError(b, err)
+	keys := make([][]byte, 0, 500)
+	for i := 0; i < 500; i++ {
+		keys = append(keys, GetKey(allkeys, Middle))
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				proof, err := tree.GetMembershipProof(key)
+				require.NoError(b, err)
+				sink = proof
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			proof, err := tree.GetBatchProof(keys)
+			require.NoError(b, err)
+			sink = proof
+		}
+	})
+	b.Run("compressed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			proof, err := tree.GetCompressedBatchProof(keys)
+			require.NoError(b, err)
+			sink = proof
+		}
+	})
+	sink = nil
+}
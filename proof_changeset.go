@@ -0,0 +1,209 @@
+package iavl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// KVPair is a change of a key: it is either set to Value, or deleted.
+type KVPair struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// ChangeSet is the changes made to a tree in a version, in the order they were applied.
+type ChangeSet struct {
+	Version int64
+	Pairs   []KVPair
+}
+
+// ChangesetProof proves that a version of a tree was derived from a previous one by a stated
+// list of change sets. It holds the nodes of the previous version touched when applying the
+// changes, including the ones read to rebalance the tree: replaying the changes on them yields
+// the root hash of the new version, without the rest of either tree.
+type ChangesetProof struct {
+	// Nodes are the encodings of the nodes, sorted by hash.
+	Nodes [][]byte
+}
+
+/*
+GetChangesetProof returns a proof that applying changesets to the saved version fromVersion
+derives the saved versions of changesets, which must follow one another. The changes of each
+version must be given in the order they were applied to the tree, as the shape of the tree depends
+on it. An error is returned if they do not derive the root hash of each version.
+
+The proof can be verified against the root hashes of fromVersion and of the last version of
+changesets with VerifyChangesetProof.
+*/
+func (tree *MutableTree) GetChangesetProof(fromVersion int64, changesets []*ChangeSet) (*ChangesetProof, error) {
+	rootHash, err := tree.ndb.getRoot(fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	if rootHash == nil {
+		return nil, fmt.Errorf("%w: %d", ErrVersionDoesNotExist, fromVersion)
+	}
+
+	// Replay the changes on a scratch tree reading from the database, recording the nodes read.
+	recorder := &nodeRecordingDB{
+		readOnlyDB: &readOnlyDB{DB: tree.ndb.db},
+		nodes:      make(map[string][]byte),
+	}
+	replay, err := newReplayTree(recorder, rootHash, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	_, err = replay.replayChangesets(changesets, func(version int64, hash []byte) error {
+		expected, err := tree.ndb.getRoot(version)
+		if err != nil {
+			return err
+		}
+		if expected == nil {
+			return fmt.Errorf("%w: %d", ErrVersionDoesNotExist, version)
+		}
+		if len(expected) == 0 {
+			expected = sha256.New().Sum(nil)
+		}
+		if !bytes.Equal(hash, expected) {
+			return fmt.Errorf("changes of version %d derive root hash %X, expected %X", version, hash, expected)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(recorder.nodes))
+	for hash := range recorder.nodes {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	proof := &ChangesetProof{Nodes: make([][]byte, len(hashes))}
+	for i, hash := range hashes {
+		proof.Nodes[i], err = decompressBytes(recorder.nodes[hash])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+/*
+VerifyChangesetProof verifies that applying changesets to the tree with root hash oldRoot derives
+the tree with root hash newRoot, replaying the changes on the nodes of proof. It returns an error
+wrapping ErrInvalidProof if the proof lacks a node needed by the changes, and ErrInvalidRoot if
+the changes do not derive newRoot.
+*/
+func VerifyChangesetProof(oldRoot, newRoot []byte, changesets []*ChangeSet, proof *ChangesetProof) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil changeset proof", ErrInvalidProof)
+	}
+
+	db, err := newNodesDB(proof.Nodes)
+	if err != nil {
+		return err
+	}
+	replay, err := newReplayTree(db, oldRoot, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	hash, err := replay.replayChangesets(changesets, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if !bytes.Equal(hash, newRoot) {
+		return fmt.Errorf("%w: changes derive root hash %X, expected %X", ErrInvalidRoot, hash, newRoot)
+	}
+	return nil
+}
+
+// newNodesDB returns a database holding the given node encodings. The nodes are stored by their
+// own hash, so that a node can only be found through the hash its parent commits to.
+func newNodesDB(nodes [][]byte) (dbm.DB, error) {
+	db := dbm.NewMemDB()
+	for _, bz := range nodes {
+		node, err := MakeNode(bz)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+		hash, err := node._hash()
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Set(nodeKeyFormat.Key(hash), bz); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// newReplayTree returns a tree over db, with the root of the given hash at version, on which
+// changes can be replayed. It never writes to db.
+func newReplayTree(db dbm.DB, rootHash []byte, version int64) (*MutableTree, error) {
+	tree, err := NewMutableTreeWithOpts(db, 0, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	tree.version = version
+	if len(rootHash) > 0 && !bytes.Equal(rootHash, sha256.New().Sum(nil)) {
+		tree.root, err = tree.ndb.GetNode(rootHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// replayChangesets applies changesets to the working tree, as if each of them was saved as a
+// version, and returns the resulting root hash. If check is not nil, it is called with the root
+// hash of each version.
+func (tree *MutableTree) replayChangesets(changesets []*ChangeSet, check func(version int64, hash []byte) error) ([]byte, error) {
+	for i, cs := range changesets {
+		if i > 0 && cs.Version <= changesets[i-1].Version {
+			return nil, fmt.Errorf("changeset versions must increase, got %d after %d", cs.Version, changesets[i-1].Version)
+		}
+		// The nodes created by the changes take the version the tree would be saved as.
+		tree.version = cs.Version - 1
+		for _, pair := range cs.Pairs {
+			var err error
+			if pair.Delete {
+				_, _, err = tree.Remove(pair.Key)
+			} else {
+				_, err = tree.Set(pair.Key, pair.Value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("replaying changes of version %d: %w", cs.Version, err)
+			}
+		}
+		hash, err := tree.WorkingHash()
+		if err != nil {
+			return nil, err
+		}
+		tree.version = cs.Version
+		if check != nil {
+			if err := check(cs.Version, hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tree.WorkingHash()
+}
+
+// nodeRecordingDB is a read-only database recording the nodes read from it, by hash.
+type nodeRecordingDB struct {
+	*readOnlyDB
+	nodes map[string][]byte
+}
+
+func (db *nodeRecordingDB) Get(key []byte) ([]byte, error) {
+	value, err := db.readOnlyDB.Get(key)
+	if err == nil && value != nil && bytes.HasPrefix(key, []byte(nodeKeyFormat.Prefix())) {
+		db.nodes[string(key[len(nodeKeyFormat.Prefix()):])] = value
+	}
+	return value, err
+}
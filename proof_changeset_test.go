@@ -0,0 +1,121 @@
+package iavl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+// applyChangeSet applies cs to tree and saves it as a version, returning the root hash.
+func applyChangeSet(t *testing.T, tree *MutableTree, cs *ChangeSet) []byte {
+	for _, pair := range cs.Pairs {
+		var err error
+		if pair.Delete {
+			_, _, err = tree.Remove(pair.Key)
+		} else {
+			_, err = tree.Set(pair.Key, pair.Value)
+		}
+		require.NoError(t, err)
+	}
+	hash, version, err := tree.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, cs.Version, version)
+	return hash
+}
+
+// randomChangeSet returns random changes for version, setting new and existing keys of tree in
+// random order, and deleting some of them.
+func randomChangeSet(r *rand.Rand, version int64, n int) *ChangeSet {
+	cs := &ChangeSet{Version: version}
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%04d", r.Intn(1000)))
+		if r.Intn(4) == 0 {
+			cs.Pairs = append(cs.Pairs, KVPair{Key: key, Delete: true})
+		} else {
+			cs.Pairs = append(cs.Pairs, KVPair{Key: key, Value: []byte(fmt.Sprintf("value%d-%d", version, i))})
+		}
+	}
+	return cs
+}
+
+func TestChangesetProof(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+
+	roots := map[int64][]byte{}
+	var changesets []*ChangeSet
+	for version := int64(1); version <= 10; version++ {
+		n := 20
+		if version == 1 {
+			n = 500
+		}
+		cs := randomChangeSet(r, version, n)
+		changesets = append(changesets, cs)
+		roots[version] = applyChangeSet(t, tree, cs)
+	}
+
+	for _, tc := range []struct{ from, to int64 }{{1, 2}, {1, 10}, {5, 6}, {9, 10}} {
+		proof, err := tree.GetChangesetProof(tc.from, changesets[tc.from:tc.to])
+		require.NoError(t, err)
+		require.NoError(t, VerifyChangesetProof(roots[tc.from], roots[tc.to], changesets[tc.from:tc.to], proof))
+
+		// The proof only holds the touched nodes.
+		if tc.to == tc.from+1 {
+			require.Less(t, len(proof.Nodes), int(tree.Size()))
+		}
+
+		// Other changes, or roots, are rejected.
+		require.ErrorIs(t, VerifyChangesetProof(roots[tc.from], roots[tc.to-1], changesets[tc.from:tc.to], proof), ErrInvalidRoot)
+		altered := &ChangeSet{Version: changesets[tc.from].Version, Pairs: append([]KVPair{}, changesets[tc.from].Pairs...)}
+		altered.Pairs[0].Value = []byte("altered")
+		altered.Pairs[0].Delete = false
+		alteredChangesets := append([]*ChangeSet{altered}, changesets[tc.from+1:tc.to]...)
+		require.Error(t, VerifyChangesetProof(roots[tc.from], roots[tc.to], alteredChangesets, proof))
+
+		// As is a proof missing a node.
+		missing := &ChangesetProof{Nodes: proof.Nodes[1:]}
+		require.ErrorIs(t, VerifyChangesetProof(roots[tc.from], roots[tc.to], changesets[tc.from:tc.to], missing), ErrInvalidProof)
+	}
+
+	// Changes that do not derive the saved versions are rejected when generating the proof.
+	altered := &ChangeSet{Version: 6, Pairs: append([]KVPair{}, changesets[5].Pairs...)}
+	altered.Pairs = append(altered.Pairs, KVPair{Key: []byte("other"), Value: []byte("value")})
+	_, err = tree.GetChangesetProof(5, []*ChangeSet{altered})
+	require.Error(t, err)
+
+	_, err = tree.GetChangesetProof(10, []*ChangeSet{{Version: 11}})
+	require.ErrorIs(t, err, ErrVersionDoesNotExist)
+	_, err = tree.GetChangesetProof(11, nil)
+	require.ErrorIs(t, err, ErrVersionDoesNotExist)
+}
+
+func TestChangesetProof_EmptyTrees(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	empty, _, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	cs := &ChangeSet{Version: 2, Pairs: []KVPair{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("2")},
+	}}
+	root := applyChangeSet(t, tree, cs)
+	proof, err := tree.GetChangesetProof(1, []*ChangeSet{cs})
+	require.NoError(t, err)
+	require.Empty(t, proof.Nodes)
+	require.NoError(t, VerifyChangesetProof(empty, root, []*ChangeSet{cs}, proof))
+
+	// Removing all the keys yields the empty tree again.
+	cs = &ChangeSet{Version: 3, Pairs: []KVPair{
+		{Key: []byte("a"), Delete: true},
+		{Key: []byte("b"), Delete: true},
+	}}
+	require.Equal(t, empty, applyChangeSet(t, tree, cs))
+	proof, err = tree.GetChangesetProof(2, []*ChangeSet{cs})
+	require.NoError(t, err)
+	require.NoError(t, VerifyChangesetProof(root, empty, []*ChangeSet{cs}, proof))
+}
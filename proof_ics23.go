@@ -10,6 +10,9 @@ import (
 /*
 GetMembershipProof will produce a CommitmentProof that the given key (and queries value) exists in the iavl tree.
 If the key doesn't exist in the tree, this will return an error.
+
+Called on a MutableTree, it proves the key in the working tree, unsaved changes included, so that
+the proof is verified against WorkingHash() rather than Hash().
 */
 func (t *ImmutableTree) GetMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
 	exist, err := t.createExistenceProof(key)
@@ -26,7 +29,9 @@ func (t *ImmutableTree) GetMembershipProof(key []byte) (*ics23.CommitmentProof,
 
 // VerifyMembership returns true iff proof is an ExistenceProof for the given key.
 func (t *ImmutableTree) VerifyMembership(proof *ics23.CommitmentProof, key []byte) (bool, error) {
-	val, err := t.Get(key)
+	// The value is read from the nodes rather than the fast storage, which only holds the saved
+	// state, so that proofs of the working tree of a MutableTree verify too.
+	_, val, err := t.GetWithIndex(key)
 	if err != nil {
 		return false, err
 	}
@@ -35,12 +40,14 @@ func (t *ImmutableTree) VerifyMembership(proof *ics23.CommitmentProof, key []byt
 		return false, err
 	}
 
-	return ics23.VerifyMembership(ics23.IavlSpec, root, proof, key, val), nil
+	return VerifyMembership(root, proof, key, val), nil
 }
 
 /*
 GetNonMembershipProof will produce a CommitmentProof that the given key doesn't exist in the iavl tree.
 If the key exists in the tree, this will return an error.
+
+Like GetMembershipProof, it proves the working tree when called on a MutableTree.
 */
 func (t *ImmutableTree) GetNonMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
 	// idx is one node right of what we want....
@@ -98,7 +105,7 @@ func (t *ImmutableTree) VerifyNonMembership(proof *ics23.CommitmentProof, key []
 		return false, err
 	}
 
-	return ics23.VerifyNonMembership(ics23.IavlSpec, root, proof, key), nil
+	return VerifyNonMembership(root, proof, key), nil
 }
 
 // createExistenceProof will get the proof from the tree and convert the proof into a valid
@@ -136,42 +143,46 @@ func convertLeafOp(version int64) *ics23.LeafOp {
 func convertInnerOps(path PathToLeaf) []*ics23.InnerOp {
 	steps := make([]*ics23.InnerOp, 0, len(path))
 
+	// we need to go in reverse order, iavl starts from root to leaf,
+	// we want to go up from the leaf to the root
+	for i := len(path) - 1; i >= 0; i-- {
+		steps = append(steps, convertInnerOp(path[i]))
+	}
+	return steps
+}
+
+// convertInnerOp converts a single inner node of a path to an ics23 inner op.
+func convertInnerOp(pin ProofInnerNode) *ics23.InnerOp {
 	// lengthByte is the length prefix prepended to each of the sha256 sub-hashes
 	var lengthByte byte = 0x20
 
 	var varintBuf [binary.MaxVarintLen64]byte
 
-	// we need to go in reverse order, iavl starts from root to leaf,
-	// we want to go up from the leaf to the root
-	for i := len(path) - 1; i >= 0; i-- {
-		// this is adapted from iavl/proof.go:proofInnerNode.Hash()
-		prefix := convertVarIntToBytes(int64(path[i].Height), varintBuf)
-		prefix = append(prefix, convertVarIntToBytes(path[i].Size, varintBuf)...)
-		prefix = append(prefix, convertVarIntToBytes(path[i].Version, varintBuf)...)
-
-		var suffix []byte
-		if len(path[i].Left) > 0 {
-			// length prefixed left side
-			prefix = append(prefix, lengthByte)
-			prefix = append(prefix, path[i].Left...)
-			// prepend the length prefix for child
-			prefix = append(prefix, lengthByte)
-		} else {
-			// prepend the length prefix for child
-			prefix = append(prefix, lengthByte)
-			// length-prefixed right side
-			suffix = []byte{lengthByte}
-			suffix = append(suffix, path[i].Right...)
-		}
-
-		op := &ics23.InnerOp{
-			Hash:   ics23.HashOp_SHA256,
-			Prefix: prefix,
-			Suffix: suffix,
-		}
-		steps = append(steps, op)
+	// this is adapted from iavl/proof.go:proofInnerNode.Hash()
+	prefix := convertVarIntToBytes(int64(pin.Height), varintBuf)
+	prefix = append(prefix, convertVarIntToBytes(pin.Size, varintBuf)...)
+	prefix = append(prefix, convertVarIntToBytes(pin.Version, varintBuf)...)
+
+	var suffix []byte
+	if len(pin.Left) > 0 {
+		// length prefixed left side
+		prefix = append(prefix, lengthByte)
+		prefix = append(prefix, pin.Left...)
+		// prepend the length prefix for child
+		prefix = append(prefix, lengthByte)
+	} else {
+		// prepend the length prefix for child
+		prefix = append(prefix, lengthByte)
+		// length-prefixed right side
+		suffix = []byte{lengthByte}
+		suffix = append(suffix, pin.Right...)
+	}
+
+	return &ics23.InnerOp{
+		Hash:   ics23.HashOp_SHA256,
+		Prefix: prefix,
+		Suffix: suffix,
 	}
-	return steps
 }
 
 func convertVarIntToBytes(orig int64, buf [binary.MaxVarintLen64]byte) []byte {
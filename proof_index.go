@@ -0,0 +1,83 @@
+package iavl
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/cosmos/iavl/verify"
+)
+
+// LeafProof proves a leaf of a tree, along with its index and the size of the tree. It is
+// defined in the verify package.
+type LeafProof = verify.LeafProof
+
+// GetIndexProof returns the key and value at index in the tree, as GetByIndex does, along with a
+// proof that they are at that index. The proof can be verified against the root hash of the tree
+// with VerifyIndexProof.
+func (t *ImmutableTree) GetIndexProof(index int64) (key, value []byte, proof *LeafProof, err error) {
+	key, value, err = t.GetByIndex(index)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if key == nil {
+		return nil, nil, nil, fmt.Errorf("%w: index %d out of range", ErrInvalidInputs, index)
+	}
+	proof, err = t.getLeafProof(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return key, value, proof, nil
+}
+
+// GetSizeProof returns the size of the tree, along with a proof of it. The empty tree needs no
+// proof, so it is nil if the size is 0. The proof can be verified against the root hash of the
+// tree with VerifySizeProof.
+func (t *ImmutableTree) GetSizeProof() (size int64, proof *LeafProof, err error) {
+	if t.root == nil {
+		return 0, nil, nil
+	}
+	// Any leaf will do, as they all prove the size committed into the root.
+	key, _, err := t.GetByIndex(0)
+	if err != nil {
+		return 0, nil, err
+	}
+	proof, err = t.getLeafProof(key)
+	if err != nil {
+		return 0, nil, err
+	}
+	return t.Size(), proof, nil
+}
+
+// getLeafProof returns the proof of the leaf of key, which must exist in the tree.
+func (t *ImmutableTree) getLeafProof(key []byte) (*LeafProof, error) {
+	if _, err := t.Hash(); err != nil {
+		return nil, err
+	}
+	path, node, err := t.root.PathToLeaf(t, key)
+	if err != nil {
+		return nil, err
+	}
+	valueHash := sha256.Sum256(node.value)
+	return &LeafProof{
+		Path: path,
+		Leaf: ProofLeafNode{
+			Key:       node.key,
+			ValueHash: valueHash[:],
+			Version:   node.version,
+		},
+	}, nil
+}
+
+// VerifyIndexProof verifies that proof, as returned by GetIndexProof, proves that key is the key
+// at index in the tree with the given root hash, with value. It returns an error wrapping
+// ErrInvalidProof or ErrInvalidRoot if it does not.
+func VerifyIndexProof(rootHash []byte, index int64, key, value []byte, proof *LeafProof) error {
+	return verify.Index(rootHash, index, key, value, proof)
+}
+
+// VerifySizeProof verifies that proof, as returned by GetSizeProof, proves that the tree with the
+// given root hash has size keys. It returns an error wrapping ErrInvalidProof or ErrInvalidRoot
+// if it does not.
+func VerifySizeProof(rootHash []byte, size int64, proof *LeafProof) error {
+	return verify.Size(rootHash, size, proof)
+}
@@ -0,0 +1,71 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetIndexProof(t *testing.T) {
+	tree, allkeys, err := BuildTree(300, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	for _, index := range []int64{0, 1, 2, 150, 298, 299} {
+		key, value, proof, err := tree.GetIndexProof(index)
+		require.NoError(t, err)
+		require.Equal(t, allkeys[index], key)
+		require.NoError(t, VerifyIndexProof(root, index, key, value, proof), index)
+
+		require.ErrorIs(t, VerifyIndexProof(root, index+1, key, value, proof), ErrInvalidProof)
+		require.ErrorIs(t, VerifyIndexProof(root, index, key, []byte("other"), proof), ErrInvalidProof)
+		require.ErrorIs(t, VerifyIndexProof([]byte("root"), index, key, value, proof), ErrInvalidRoot)
+	}
+
+	// A proof of another index does not prove the key at this one.
+	key, value, _, err := tree.GetIndexProof(10)
+	require.NoError(t, err)
+	_, _, other, err := tree.GetIndexProof(11)
+	require.NoError(t, err)
+	require.Error(t, VerifyIndexProof(root, 10, key, value, other))
+
+	_, _, _, err = tree.GetIndexProof(300)
+	require.ErrorIs(t, err, ErrInvalidInputs)
+	_, _, _, err = tree.GetIndexProof(-1)
+	require.Error(t, err)
+}
+
+func TestGetSizeProof(t *testing.T) {
+	tree := setupMutableTree(t, false)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+	size, proof, err := tree.GetSizeProof()
+	require.NoError(t, err)
+	require.EqualValues(t, 0, size)
+	require.NoError(t, VerifySizeProof(root, 0, proof))
+	require.ErrorIs(t, VerifySizeProof(root, 1, proof), ErrInvalidProof)
+
+	for i := 1; i <= 40; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+		require.NoError(t, err)
+		root, err := tree.WorkingHash()
+		require.NoError(t, err)
+
+		size, proof, err := tree.GetSizeProof()
+		require.NoError(t, err)
+		require.EqualValues(t, i, size)
+		require.NoError(t, VerifySizeProof(root, size, proof))
+		require.ErrorIs(t, VerifySizeProof(root, size+1, proof), ErrInvalidProof)
+		if size > 1 {
+			require.ErrorIs(t, VerifySizeProof(root, size-1, proof), ErrInvalidProof)
+		}
+		require.ErrorIs(t, VerifySizeProof(root, 0, proof), ErrInvalidRoot)
+
+		// The proof of any index also proves the size.
+		_, _, indexProof, err := tree.GetIndexProof(size - 1)
+		require.NoError(t, err)
+		require.NoError(t, VerifySizeProof(root, size, indexProof))
+	}
+}
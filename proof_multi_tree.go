@@ -0,0 +1,92 @@
+package iavl
+
+import (
+	"fmt"
+
+	ics23 "github.com/confio/ics23/go"
+)
+
+// MultiTreeProofSpecs are the specs of the two layers of a MultiTree proof: the proof of a key
+// in a tree, and the proof of the root hash of the tree in the merkle map of the MultiTree.
+var MultiTreeProofSpecs = []*ics23.ProofSpec{ics23.IavlSpec, ics23.TendermintSpec}
+
+// GetMembershipProof returns a proof that key exists in the tree named name, against the
+// combined root hash. It is made of an existence proof of the key in the tree, followed by an
+// existence proof of the root hash of the tree in the merkle map, as specified by
+// MultiTreeProofSpecs.
+func (m *ImmutableMultiTree) GetMembershipProof(name string, key []byte) ([]*ics23.CommitmentProof, error) {
+	tree, ok := m.trees[name]
+	if !ok {
+		return nil, fmt.Errorf("no tree named %q", name)
+	}
+	proof, err := tree.GetMembershipProof(key)
+	if err != nil {
+		return nil, err
+	}
+	return m.chainProof(name, proof)
+}
+
+// GetNonMembershipProof returns a proof that key does not exist in the tree named name, against
+// the combined root hash. It is made of a non-existence proof of the key in the tree, followed by
+// an existence proof of the root hash of the tree in the merkle map, as specified by
+// MultiTreeProofSpecs.
+func (m *ImmutableMultiTree) GetNonMembershipProof(name string, key []byte) ([]*ics23.CommitmentProof, error) {
+	tree, ok := m.trees[name]
+	if !ok {
+		return nil, fmt.Errorf("no tree named %q", name)
+	}
+	proof, err := tree.GetNonMembershipProof(key)
+	if err != nil {
+		return nil, err
+	}
+	return m.chainProof(name, proof)
+}
+
+// chainProof appends to the proof of a key in the tree named name the proof of the tree's root
+// hash in the merkle map.
+func (m *ImmutableMultiTree) chainProof(name string, proof *ics23.CommitmentProof) ([]*ics23.CommitmentProof, error) {
+	hashes, err := m.RootHashes()
+	if err != nil {
+		return nil, err
+	}
+	exist, err := merkleMapExistenceProof(hashes, name)
+	if err != nil {
+		return nil, err
+	}
+	return []*ics23.CommitmentProof{
+		proof,
+		{Proof: &ics23.CommitmentProof_Exist{Exist: exist}},
+	}, nil
+}
+
+// VerifyMultiTreeMembership returns true iff proof, as returned by
+// ImmutableMultiTree.GetMembershipProof, proves that key exists with value in the tree named
+// name of the MultiTree with the given combined root hash.
+func VerifyMultiTreeMembership(proof []*ics23.CommitmentProof, root []byte, name string, key, value []byte) bool {
+	treeRoot, ok := verifyMultiTreeRoot(proof, root, name)
+	return ok && ics23.VerifyMembership(MultiTreeProofSpecs[0], treeRoot, proof[0], key, value)
+}
+
+// VerifyMultiTreeNonMembership returns true iff proof, as returned by
+// ImmutableMultiTree.GetNonMembershipProof, proves that key does not exist in the tree named
+// name of the MultiTree with the given combined root hash.
+func VerifyMultiTreeNonMembership(proof []*ics23.CommitmentProof, root []byte, name string, key []byte) bool {
+	treeRoot, ok := verifyMultiTreeRoot(proof, root, name)
+	return ok && ics23.VerifyNonMembership(MultiTreeProofSpecs[0], treeRoot, proof[0], key)
+}
+
+// verifyMultiTreeRoot calculates the root hash of the tree from the first layer of proof, and
+// verifies it against root with the second layer. It returns the root hash of the tree.
+func verifyMultiTreeRoot(proof []*ics23.CommitmentProof, root []byte, name string) ([]byte, bool) {
+	if len(proof) != len(MultiTreeProofSpecs) || proof[0] == nil || proof[1] == nil {
+		return nil, false
+	}
+	treeRoot, err := proof[0].Calculate()
+	if err != nil {
+		return nil, false
+	}
+	if !ics23.VerifyMembership(MultiTreeProofSpecs[1], root, proof[1], []byte(name), treeRoot) {
+		return nil, false
+	}
+	return treeRoot, true
+}
@@ -0,0 +1,97 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	ics23 "github.com/confio/ics23/go"
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleMapExistenceProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		values := make(map[string][]byte, n)
+		for i := 0; i < n; i++ {
+			values[fmt.Sprintf("tree%d", i)] = []byte(fmt.Sprintf("hash%d", i))
+		}
+		root := merkleMapRoot(values)
+		for name, value := range values {
+			exist, err := merkleMapExistenceProof(values, name)
+			require.NoError(t, err)
+			proof := &ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Exist{Exist: exist}}
+			require.True(t, ics23.VerifyMembership(ics23.TendermintSpec, root, proof, []byte(name), value), "%d values, %s", n, name)
+			require.False(t, ics23.VerifyMembership(ics23.TendermintSpec, root, proof, []byte(name), []byte("other")))
+		}
+	}
+
+	_, err := merkleMapExistenceProof(map[string][]byte{"a": nil}, "b")
+	require.Error(t, err)
+}
+
+func setupMultiTreeProofs(t *testing.T) (*ImmutableMultiTree, []byte) {
+	m, err := NewMultiTree(db.NewMemDB(), []string{"acc", "bank", "gov", "staking", "upgrade"}, 0, nil, false)
+	require.NoError(t, err)
+	_, err = m.Load()
+	require.NoError(t, err)
+	for _, name := range m.Names() {
+		for i := 0; i < 20; i += 2 {
+			_, err := m.Tree(name).Set([]byte(fmt.Sprintf("%s/%02d", name, i)), []byte(fmt.Sprintf("value%d", i)))
+			require.NoError(t, err)
+		}
+	}
+	hash, version, err := m.SaveVersion()
+	require.NoError(t, err)
+	itree, err := m.GetImmutable(version)
+	require.NoError(t, err)
+	return itree, hash
+}
+
+func TestMultiTree_MembershipProof(t *testing.T) {
+	itree, root := setupMultiTreeProofs(t)
+	itreeRoot, err := itree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, root, itreeRoot)
+
+	for _, name := range []string{"acc", "gov", "upgrade"} {
+		key := []byte(name + "/04")
+		proof, err := itree.GetMembershipProof(name, key)
+		require.NoError(t, err)
+		require.Len(t, proof, 2)
+		require.True(t, VerifyMultiTreeMembership(proof, root, name, key, []byte("value4")))
+
+		// Each layer verifies with its own spec.
+		treeRoot, err := itree.Tree(name).Hash()
+		require.NoError(t, err)
+		require.True(t, ics23.VerifyMembership(MultiTreeProofSpecs[0], treeRoot, proof[0], key, []byte("value4")))
+		require.True(t, ics23.VerifyMembership(MultiTreeProofSpecs[1], root, proof[1], []byte(name), treeRoot))
+
+		require.False(t, VerifyMultiTreeMembership(proof, root, name, key, []byte("value5")))
+		require.False(t, VerifyMultiTreeMembership(proof, root, name, []byte(name+"/06"), []byte("value4")))
+		require.False(t, VerifyMultiTreeMembership(proof, root, "bank", key, []byte("value4")))
+		require.False(t, VerifyMultiTreeMembership(proof, []byte("root"), name, key, []byte("value4")))
+		require.False(t, VerifyMultiTreeMembership(proof[:1], root, name, key, []byte("value4")))
+		require.False(t, VerifyMultiTreeNonMembership(proof, root, name, key))
+	}
+
+	_, err = itree.GetMembershipProof("acc", []byte("acc/05"))
+	require.Error(t, err)
+	_, err = itree.GetMembershipProof("missing", []byte("acc/04"))
+	require.Error(t, err)
+}
+
+func TestMultiTree_NonMembershipProof(t *testing.T) {
+	itree, root := setupMultiTreeProofs(t)
+
+	for _, key := range []string{"bank/", "bank/05", "bank/99"} {
+		proof, err := itree.GetNonMembershipProof("bank", []byte(key))
+		require.NoError(t, err)
+		require.True(t, VerifyMultiTreeNonMembership(proof, root, "bank", []byte(key)), key)
+		require.False(t, VerifyMultiTreeNonMembership(proof, root, "acc", []byte(key)), key)
+		require.False(t, VerifyMultiTreeNonMembership(proof, root, "bank", []byte("bank/04")), key)
+		require.False(t, VerifyMultiTreeMembership(proof, root, "bank", []byte(key), nil), key)
+	}
+
+	_, err := itree.GetNonMembershipProof("bank", []byte("bank/04"))
+	require.Error(t, err)
+}
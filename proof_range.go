@@ -0,0 +1,102 @@
+package iavl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/cosmos/iavl/verify"
+)
+
+/*
+GetRangeProof returns the keys and values of the tree in the range [start, end), along with a
+proof that they are all of them. A nil start or end leaves the range open on that side. If limit
+is positive, at most limit keys are returned, starting from start, and the proof only covers the
+keys up to the last one returned.
+
+The proof can be verified against the root hash of the tree with VerifyRangeProof.
+*/
+func (t *ImmutableTree) GetRangeProof(start, end []byte, limit int) (keys, values [][]byte, proof *RangeProof, err error) {
+	if start != nil && end != nil && bytes.Compare(start, end) >= 0 {
+		return nil, nil, nil, fmt.Errorf("%w: range start must be less than its end", ErrInvalidInputs)
+	}
+	if limit < 0 {
+		return nil, nil, nil, fmt.Errorf("%w: limit must not be negative", ErrInvalidInputs)
+	}
+
+	proof = &RangeProof{}
+	if t.root == nil {
+		return nil, nil, proof, nil
+	}
+	if _, err := t.Hash(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The index of the first key in range, and of the first leaf of the proof.
+	idx := int64(0)
+	if start != nil {
+		idx, _, err = t.GetWithIndex(start)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	first := idx
+	if idx > 0 {
+		first = idx - 1
+	}
+
+	var prevPath PathToLeaf
+	for i := first; i < t.Size(); i++ {
+		key, value, err := t.GetByIndex(i)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		path, node, err := t.root.PathToLeaf(t, key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if i == first {
+			proof.LeftPath = path
+		} else {
+			d := divergence(prevPath, path)
+			proof.InnerNodes = append(proof.InnerNodes, path[d+1:])
+		}
+		valueHash := sha256.Sum256(node.value)
+		proof.Leaves = append(proof.Leaves, ProofLeafNode{
+			Key:       node.key,
+			ValueHash: valueHash[:],
+			Version:   node.version,
+		})
+		prevPath = path
+
+		if i < idx {
+			continue // The left boundary.
+		}
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break // The right boundary.
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		if limit > 0 && len(keys) == limit {
+			break
+		}
+	}
+	return keys, values, proof, nil
+}
+
+// divergence returns the depth of the node where the paths to two consecutive leaves diverge, the
+// first one going left and the second one going right.
+func divergence(prev, next PathToLeaf) int {
+	d := 0
+	for d < len(prev)-1 && d < len(next)-1 && (prev[d].Left == nil) == (next[d].Left == nil) {
+		d++
+	}
+	return d
+}
+
+// VerifyRangeProof verifies that keys and values, as returned by GetRangeProof with the same
+// start, end and limit, are all the key/value pairs in the range of the tree with the given root
+// hash. It returns an error wrapping ErrInvalidProof or ErrInvalidRoot if they are not.
+func VerifyRangeProof(rootHash, start, end []byte, limit int, keys, values [][]byte, proof *RangeProof) error {
+	return verify.Range(rootHash, start, end, limit, keys, values, proof)
+}
@@ -0,0 +1,141 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRangeProof(t *testing.T) {
+	tree, allkeys, err := BuildTree(500, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		start, end []byte
+		limit      int
+	}{
+		{nil, nil, 0},
+		{nil, nil, 10},
+		{allkeys[0], allkeys[len(allkeys)-1], 0},
+		{GetNonKey(allkeys, Left), GetNonKey(allkeys, Right), 0},
+		{allkeys[100], allkeys[200], 0},
+		{allkeys[100], allkeys[200], 50},
+		{allkeys[100], allkeys[200], 100},
+		{allkeys[100], allkeys[101], 0},
+		{GetNonKey(allkeys, Middle), nil, 20},
+		{nil, GetNonKey(allkeys, Middle), 0},
+		{allkeys[len(allkeys)-1], nil, 0},
+		{GetNonKey(allkeys, Right), nil, 0},
+		{nil, GetNonKey(allkeys, Left), 0},
+		{[]byte{0x80}, []byte{0x80, 0, 0, 0, 0}, 0}, // Likely an empty range in the middle.
+	}
+	for i, tc := range testCases {
+		keys, values, proof, err := tree.GetRangeProof(tc.start, tc.end, tc.limit)
+		require.NoError(t, err, i)
+		require.NoError(t, VerifyRangeProof(root, tc.start, tc.end, tc.limit, keys, values, proof), i)
+
+		var expected [][]byte
+		tree.IterateRange(tc.start, tc.end, true, func(key, value []byte) bool {
+			expected = append(expected, key)
+			return tc.limit > 0 && len(expected) == tc.limit
+		})
+		require.Equal(t, expected, keys, i)
+		for j, key := range keys {
+			value, err := tree.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, value, values[j])
+		}
+
+		// The proof only holds the boundary leaves besides the keys in range.
+		require.LessOrEqual(t, len(proof.Leaves), len(keys)+2, i)
+		require.Len(t, proof.InnerNodes, len(proof.Leaves)-1, i)
+
+		if len(keys) == 0 {
+			continue
+		}
+		// Omitting a key, at either end or in the middle, is detected.
+		for _, j := range []int{0, len(keys) / 2, len(keys) - 1} {
+			omitted := append(append([][]byte{}, keys[:j]...), keys[j+1:]...)
+			omittedValues := append(append([][]byte{}, values[:j]...), values[j+1:]...)
+			require.ErrorIs(t, VerifyRangeProof(root, tc.start, tc.end, tc.limit, omitted, omittedValues, proof), ErrInvalidProof, i)
+		}
+		// So is a wrong value, or a wrong root hash.
+		altered := append([][]byte{}, values...)
+		altered[0] = []byte("altered")
+		require.ErrorIs(t, VerifyRangeProof(root, tc.start, tc.end, tc.limit, keys, altered, proof), ErrInvalidProof, i)
+		require.ErrorIs(t, VerifyRangeProof([]byte("root"), tc.start, tc.end, tc.limit, keys, values, proof), ErrInvalidRoot, i)
+	}
+}
+
+func TestGetRangeProof_Tampering(t *testing.T) {
+	tree := setupMutableTree(t, false)
+	for i := 0; i < 20; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%d", i)))
+		require.NoError(t, err)
+	}
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	start, end := []byte("key05"), []byte("key10")
+	keys, values, proof, err := tree.GetRangeProof(start, end, 0)
+	require.NoError(t, err)
+	require.Len(t, keys, 5)
+	require.NoError(t, VerifyRangeProof(root, start, end, 0, keys, values, proof))
+
+	// Dropping a leaf from the proof changes the root hash.
+	dropped := &RangeProof{
+		LeftPath:   proof.LeftPath,
+		InnerNodes: proof.InnerNodes[:len(proof.InnerNodes)-1],
+		Leaves:     proof.Leaves[:len(proof.Leaves)-1],
+	}
+	require.Error(t, VerifyRangeProof(root, start, end, 0, keys, values, dropped))
+
+	// The proof does not cover a larger range, nor a larger limit.
+	require.ErrorIs(t, VerifyRangeProof(root, start, []byte("key12"), 0, keys, values, proof), ErrInvalidProof)
+	require.ErrorIs(t, VerifyRangeProof(root, []byte("key03"), end, 0, keys, values, proof), ErrInvalidProof)
+
+	// A limited proof does not prove the rest of the range.
+	keys, values, proof, err = tree.GetRangeProof(start, end, 3)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.NoError(t, VerifyRangeProof(root, start, end, 3, keys, values, proof))
+	require.ErrorIs(t, VerifyRangeProof(root, start, end, 0, keys, values, proof), ErrInvalidProof)
+	require.ErrorIs(t, VerifyRangeProof(root, start, end, 4, keys, values, proof), ErrInvalidProof)
+
+	// Inner nodes with both child hashes are rejected.
+	keys, values, proof, err = tree.GetRangeProof(start, end, 0)
+	require.NoError(t, err)
+	proof.LeftPath[0].Left = proof.LeftPath[0].Right
+	proof.LeftPath[0].Right = proof.LeftPath[0].Left
+	require.ErrorIs(t, VerifyRangeProof(root, start, end, 0, keys, values, proof), ErrInvalidProof)
+
+	_, _, _, err = tree.GetRangeProof(end, start, 0)
+	require.ErrorIs(t, err, ErrInvalidInputs)
+	_, _, _, err = tree.GetRangeProof(start, end, -1)
+	require.ErrorIs(t, err, ErrInvalidInputs)
+}
+
+func TestGetRangeProof_SmallTrees(t *testing.T) {
+	tree := setupMutableTree(t, false)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+	keys, values, proof, err := tree.GetRangeProof(nil, nil, 0)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	require.NoError(t, VerifyRangeProof(root, nil, nil, 0, keys, values, proof))
+	require.Error(t, VerifyRangeProof(root, nil, nil, 0, [][]byte{[]byte("a")}, [][]byte{[]byte("1")}, proof))
+
+	_, err = tree.Set([]byte("b"), []byte("1"))
+	require.NoError(t, err)
+	root, err = tree.WorkingHash()
+	require.NoError(t, err)
+	for _, r := range [][2][]byte{{nil, nil}, {[]byte("a"), []byte("c")}, {[]byte("a"), []byte("b")}, {[]byte("c"), nil}} {
+		keys, values, proof, err := tree.GetRangeProof(r[0], r[1], 0)
+		require.NoError(t, err)
+		require.NoError(t, VerifyRangeProof(root, r[0], r[1], 0, keys, values, proof), "%s", r)
+	}
+	require.Error(t, VerifyRangeProof(root, nil, nil, 0, nil, nil, &RangeProof{}))
+}
@@ -0,0 +1,309 @@
+package iavl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	ics23 "github.com/confio/ics23/go"
+
+	hexbytes "github.com/cosmos/iavl/internal/bytes"
+	"github.com/cosmos/iavl/internal/encoding"
+	"github.com/cosmos/iavl/verify"
+)
+
+// ValueProof is a self-contained proof that a key exists with a value in the tree with a root
+// hash. It pairs the leaf of the key with the path from the root to it. Both its binary encoding,
+// with MarshalBinary, and its JSON encoding, with json.Marshal, are deterministic, so that it can
+// be exchanged without protobuf. It can be converted to and from an ics23.ExistenceProof.
+type ValueProof struct {
+	Value    hexbytes.HexBytes `json:"value"`
+	Path     PathToLeaf        `json:"path"`
+	Leaf     ProofLeafNode     `json:"leaf"`
+	RootHash hexbytes.HexBytes `json:"root_hash"`
+}
+
+// GetValueProof returns a ValueProof of key, which must exist in the tree.
+func (t *ImmutableTree) GetValueProof(key []byte) (*ValueProof, error) {
+	if t.root == nil {
+		return nil, fmt.Errorf("cannot generate the proof with nil root")
+	}
+	_, value, err := t.GetWithIndex(key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("key %X does not exist", key)
+	}
+	proof, err := t.getLeafProof(key)
+	if err != nil {
+		return nil, err
+	}
+	rootHash, err := t.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return &ValueProof{
+		Value:    value,
+		Path:     proof.Path,
+		Leaf:     proof.Leaf,
+		RootHash: rootHash,
+	}, nil
+}
+
+// Key returns the key proven by the proof.
+func (p *ValueProof) Key() []byte {
+	return p.Leaf.Key
+}
+
+// Verify verifies that the proof is a valid proof of its key and value against rootHash. It
+// returns an error wrapping ErrInvalidProof or ErrInvalidRoot if it is not.
+func (p *ValueProof) Verify(rootHash []byte) error {
+	if !bytes.Equal(p.RootHash, rootHash) {
+		return fmt.Errorf("%w: proof root hash %X, expected %X", ErrInvalidRoot, []byte(p.RootHash), rootHash)
+	}
+	return verify.Leaf(rootHash, p.Path, p.Leaf, p.Leaf.Key, p.Value)
+}
+
+// ToICS23 converts the proof to an ics23.ExistenceProof, to be verified with the ics23.IavlSpec.
+func (p *ValueProof) ToICS23() *ics23.ExistenceProof {
+	return &ics23.ExistenceProof{
+		Key:   p.Leaf.Key,
+		Value: p.Value,
+		Leaf:  convertLeafOp(p.Leaf.Version),
+		Path:  convertInnerOps(p.Path),
+	}
+}
+
+// ValueProofFromICS23 converts an ics23.ExistenceProof of a key in a tree, as returned by
+// GetMembershipProof, to a ValueProof. The root hash of the proof is calculated from it.
+func ValueProofFromICS23(exist *ics23.ExistenceProof) (*ValueProof, error) {
+	if exist == nil {
+		return nil, fmt.Errorf("%w: nil existence proof", ErrInvalidProof)
+	}
+	if err := exist.CheckAgainstSpec(ics23.IavlSpec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	rootHash, err := exist.Calculate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	version, err := parseLeafOp(exist.Leaf)
+	if err != nil {
+		return nil, err
+	}
+	valueHash := sha256.Sum256(exist.Value)
+	leaf := ProofLeafNode{Key: exist.Key, ValueHash: valueHash[:], Version: version}
+
+	// The ics23 path goes up from the leaf, while PathToLeaf goes down from the root.
+	path := make(PathToLeaf, len(exist.Path))
+	for i, op := range exist.Path {
+		pin, err := parseInnerOp(op)
+		if err != nil {
+			return nil, err
+		}
+		path[len(path)-1-i] = pin
+	}
+
+	return &ValueProof{
+		Value:    exist.Value,
+		Path:     path,
+		Leaf:     leaf,
+		RootHash: hexbytes.HexBytes(rootHash),
+	}, nil
+}
+
+// parseLeafOp returns the version of the leaf op built by convertLeafOp.
+func parseLeafOp(op *ics23.LeafOp) (int64, error) {
+	bz := op.Prefix
+	var values [3]int64
+	for i := range values {
+		value, n, err := encoding.DecodeVarint(bz)
+		if err != nil {
+			return 0, fmt.Errorf("%w: decoding leaf op: %v", ErrInvalidProof, err)
+		}
+		values[i], bz = value, bz[n:]
+	}
+	if values[0] != 0 || values[1] != 1 || len(bz) > 0 {
+		return 0, fmt.Errorf("%w: invalid leaf op prefix %X", ErrInvalidProof, op.Prefix)
+	}
+	return values[2], nil
+}
+
+// parseInnerOp returns the inner node of the inner op built by convertInnerOp.
+func parseInnerOp(op *ics23.InnerOp) (ProofInnerNode, error) {
+	bz := op.Prefix
+	var values [3]int64
+	for i := range values {
+		value, n, err := encoding.DecodeVarint(bz)
+		if err != nil {
+			return ProofInnerNode{}, fmt.Errorf("%w: decoding inner op: %v", ErrInvalidProof, err)
+		}
+		values[i], bz = value, bz[n:]
+	}
+	pin := ProofInnerNode{
+		Height:  int8(values[0]),
+		Size:    values[1],
+		Version: values[2],
+	}
+	if int64(pin.Height) != values[0] {
+		return ProofInnerNode{}, fmt.Errorf("%w: invalid inner op height %d", ErrInvalidProof, values[0])
+	}
+
+	// The rest is either the length-prefixed left hash and the length prefix of the child hash,
+	// or only the length prefix of the child hash, with the length-prefixed right hash as suffix.
+	var err error
+	switch {
+	case len(bz) == 0:
+		err = fmt.Errorf("invalid inner op prefix %X", op.Prefix)
+	case len(op.Suffix) == 0:
+		pin.Left, err = decodeChildHash(bz[:len(bz)-1])
+		if err == nil && bz[len(bz)-1] != 0x20 {
+			err = fmt.Errorf("invalid length prefix %X", bz[len(bz)-1])
+		}
+	default:
+		pin.Right, err = decodeChildHash(op.Suffix)
+		if err == nil && !bytes.Equal(bz, []byte{0x20}) {
+			err = fmt.Errorf("invalid inner op prefix %X", op.Prefix)
+		}
+	}
+	if err != nil {
+		return ProofInnerNode{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return pin, nil
+}
+
+// decodeChildHash decodes bz, which must be exactly a length-prefixed child hash.
+func decodeChildHash(bz []byte) ([]byte, error) {
+	if len(bz) == 0 {
+		return nil, fmt.Errorf("missing child hash")
+	}
+	hash, n, err := encoding.DecodeBytes(bz)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(bz) || len(hash) == 0 {
+		return nil, fmt.Errorf("invalid child hash %X", bz)
+	}
+	return hash, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is deterministic: the root
+// hash, the leaf and the value, followed by the number of inner nodes of the path and each of
+// them from the root down, all encoded with the varint and length-prefixed bytes of nodes.
+func (p *ValueProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := encoding.EncodeBytes(&buf, p.RootHash)
+	if err == nil {
+		err = encoding.EncodeBytes(&buf, p.Leaf.Key)
+	}
+	if err == nil {
+		err = encoding.EncodeBytes(&buf, p.Leaf.ValueHash)
+	}
+	if err == nil {
+		err = encoding.EncodeVarint(&buf, p.Leaf.Version)
+	}
+	if err == nil {
+		err = encoding.EncodeBytes(&buf, p.Value)
+	}
+	if err == nil {
+		err = encoding.EncodeUvarint(&buf, uint64(len(p.Path)))
+	}
+	for _, pin := range p.Path {
+		if err == nil {
+			err = encoding.EncodeVarint(&buf, int64(pin.Height))
+		}
+		if err == nil {
+			err = encoding.EncodeVarint(&buf, pin.Size)
+		}
+		if err == nil {
+			err = encoding.EncodeVarint(&buf, pin.Version)
+		}
+		if err == nil {
+			err = encoding.EncodeBytes(&buf, pin.Left)
+		}
+		if err == nil {
+			err = encoding.EncodeBytes(&buf, pin.Right)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ValueProof: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding the encoding of MarshalBinary.
+func (p *ValueProof) UnmarshalBinary(bz []byte) error {
+	d := &proofDecoder{bz: bz}
+	var proof ValueProof
+	proof.RootHash = d.bytes()
+	proof.Leaf.Key = d.bytes()
+	proof.Leaf.ValueHash = d.bytes()
+	proof.Leaf.Version = d.varint()
+	proof.Value = d.bytes()
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.bz)) {
+		d.err = fmt.Errorf("invalid path length %d", n)
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		height := d.varint()
+		pin := ProofInnerNode{
+			Height:  int8(height),
+			Size:    d.varint(),
+			Version: d.varint(),
+			Left:    d.bytes(),
+			Right:   d.bytes(),
+		}
+		if d.err == nil && int64(pin.Height) != height {
+			d.err = fmt.Errorf("invalid inner node height %d", height)
+		}
+		proof.Path = append(proof.Path, pin)
+	}
+	if d.err == nil && len(d.bz) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.bz))
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode ValueProof: %w", d.err)
+	}
+	*p = proof
+	return nil
+}
+
+// proofDecoder decodes the values of a binary encoding one after the other, stopping at the
+// first error.
+type proofDecoder struct {
+	bz  []byte
+	err error
+}
+
+// bytes decodes length-prefixed bytes, decoding empty ones as nil.
+func (d *proofDecoder) bytes() []byte {
+	if d.err != nil {
+		return nil
+	}
+	bz, n, err := encoding.DecodeBytes(d.bz)
+	d.bz, d.err = d.bz[n:], err
+	if len(bz) == 0 {
+		return nil
+	}
+	return bz
+}
+
+func (d *proofDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	i, n, err := encoding.DecodeVarint(d.bz)
+	d.bz, d.err = d.bz[n:], err
+	return i
+}
+
+func (d *proofDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	u, n, err := encoding.DecodeUvarint(d.bz)
+	d.bz, d.err = d.bz[n:], err
+	return u
+}
@@ -0,0 +1,124 @@
+package iavl
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/iavl/internal/encoding"
+)
+
+func TestValueProof(t *testing.T) {
+	tree, allkeys, err := BuildTree(200, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	for _, loc := range []Where{Left, Right, Middle} {
+		key := GetKey(allkeys, loc)
+		value, err := tree.Get(key)
+		require.NoError(t, err)
+		proof, err := tree.GetValueProof(key)
+		require.NoError(t, err)
+		require.Equal(t, key, proof.Key())
+		require.NoError(t, proof.Verify(root))
+		require.ErrorIs(t, proof.Verify([]byte("root")), ErrInvalidRoot)
+
+		// The binary encoding round-trips, and is deterministic.
+		bz, err := proof.MarshalBinary()
+		require.NoError(t, err)
+		decoded := &ValueProof{}
+		require.NoError(t, decoded.UnmarshalBinary(bz))
+		require.Equal(t, proof, decoded)
+		bz2, err := decoded.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, bz, bz2)
+		require.Error(t, decoded.UnmarshalBinary(bz[:len(bz)-1]))
+		require.Error(t, decoded.UnmarshalBinary(append(bz, 0)))
+
+		// So does the JSON one.
+		jsonBz, err := json.Marshal(proof)
+		require.NoError(t, err)
+		decoded = &ValueProof{}
+		require.NoError(t, json.Unmarshal(jsonBz, decoded))
+		require.Equal(t, proof, decoded)
+		jsonBz2, err := json.Marshal(decoded)
+		require.NoError(t, err)
+		require.Equal(t, string(jsonBz), string(jsonBz2))
+
+		// The ICS23 conversions agree with GetMembershipProof.
+		exist := proof.ToICS23()
+		membership, err := tree.GetMembershipProof(key)
+		require.NoError(t, err)
+		require.Equal(t, membership.GetExist().String(), exist.String())
+		require.True(t, VerifyMembership(root, &ics23.CommitmentProof{
+			Proof: &ics23.CommitmentProof_Exist{Exist: exist},
+		}, key, value))
+
+		converted, err := ValueProofFromICS23(membership.GetExist())
+		require.NoError(t, err)
+		require.Equal(t, proof, converted)
+
+		// A tampered value is rejected.
+		proof.Value = []byte("other")
+		require.ErrorIs(t, proof.Verify(root), ErrInvalidProof)
+	}
+
+	_, err = tree.GetValueProof(GetNonKey(allkeys, Middle))
+	require.Error(t, err)
+
+	nonexist, err := tree.GetNonMembershipProof(GetNonKey(allkeys, Middle))
+	require.NoError(t, err)
+	_, err = ValueProofFromICS23(nonexist.GetExist())
+	require.ErrorIs(t, err, ErrInvalidProof)
+
+	// Existence proofs of other specs are rejected.
+	exist, err := merkleMapExistenceProof(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, "a")
+	require.NoError(t, err)
+	_, err = ValueProofFromICS23(exist)
+	require.ErrorIs(t, err, ErrInvalidProof)
+}
+
+func TestValueProof_SingleLeaf(t *testing.T) {
+	tree := setupMutableTree(t, false)
+	_, err := tree.GetValueProof([]byte("a"))
+	require.Error(t, err)
+
+	_, err = tree.Set([]byte("a"), []byte{})
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+	proof, err := tree.GetValueProof([]byte("a"))
+	require.NoError(t, err)
+	require.Empty(t, proof.Path)
+	require.NoError(t, proof.Verify(root))
+
+	bz, err := proof.MarshalBinary()
+	require.NoError(t, err)
+	decoded := &ValueProof{}
+	require.NoError(t, decoded.UnmarshalBinary(bz))
+	require.NoError(t, decoded.Verify(root))
+}
+
+func TestValueProof_InvalidHeight(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, encoding.EncodeBytes(&buf, []byte("root")))
+	require.NoError(t, encoding.EncodeBytes(&buf, []byte("a")))
+	require.NoError(t, encoding.EncodeBytes(&buf, []byte("hash")))
+	require.NoError(t, encoding.EncodeVarint(&buf, 1))
+	require.NoError(t, encoding.EncodeBytes(&buf, []byte("value")))
+	require.NoError(t, encoding.EncodeUvarint(&buf, 1))
+	require.NoError(t, encoding.EncodeVarint(&buf, 256))
+	require.NoError(t, encoding.EncodeVarint(&buf, 2))
+	require.NoError(t, encoding.EncodeVarint(&buf, 1))
+	require.NoError(t, encoding.EncodeBytes(&buf, nil))
+	require.NoError(t, encoding.EncodeBytes(&buf, []byte("right")))
+
+	decoded := &ValueProof{}
+	err := decoded.UnmarshalBinary(buf.Bytes())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid inner node height 256")
+}
@@ -0,0 +1,37 @@
+package iavl
+
+import (
+	ics23 "github.com/confio/ics23/go"
+
+	"github.com/cosmos/iavl/verify"
+)
+
+// VerifyMembership returns true iff proof is an ExistenceProof of key with value in the tree
+// with the given root hash. Unlike ImmutableTree.VerifyMembership, it needs no tree, only a
+// trusted root hash.
+func VerifyMembership(rootHash []byte, proof *ics23.CommitmentProof, key, value []byte) bool {
+	return verify.Membership(rootHash, proof, key, value)
+}
+
+// VerifyNonMembership returns true iff proof is a NonExistenceProof of key in the tree with the
+// given root hash.
+func VerifyNonMembership(rootHash []byte, proof *ics23.CommitmentProof, key []byte) bool {
+	return verify.NonMembership(rootHash, proof, key)
+}
+
+// VerifyProof checks if proof is correct for key in the tree with the given root hash: an
+// ExistenceProof of key with value, or else a NonExistenceProof of key, in which case value is
+// ignored.
+func VerifyProof(rootHash []byte, proof *ics23.CommitmentProof, key, value []byte) bool {
+	if proof.GetExist() != nil {
+		return VerifyMembership(rootHash, proof, key, value)
+	}
+	return VerifyNonMembership(rootHash, proof, key)
+}
+
+// VerifyPathToLeaf verifies that leaf, with the path to it returned by Node.PathToLeaf, proves
+// that key exists with value in the tree with the given root hash. It returns an error wrapping
+// ErrInvalidProof or ErrInvalidRoot if it does not.
+func VerifyPathToLeaf(rootHash []byte, path PathToLeaf, leaf ProofLeafNode, key, value []byte) error {
+	return verify.Leaf(rootHash, path, leaf, key, value)
+}
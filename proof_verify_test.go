@@ -0,0 +1,60 @@
+package iavl
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWithoutTree(t *testing.T) {
+	tree, allkeys, err := BuildTree(200, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	key := GetKey(allkeys, Middle)
+	value, err := tree.Get(key)
+	require.NoError(t, err)
+	proof, err := tree.GetMembershipProof(key)
+	require.NoError(t, err)
+	require.True(t, VerifyMembership(root, proof, key, value))
+	require.True(t, VerifyProof(root, proof, key, value))
+	require.False(t, VerifyMembership(root, proof, key, []byte("other")))
+	require.False(t, VerifyMembership([]byte("root"), proof, key, value))
+	require.False(t, VerifyNonMembership(root, proof, key))
+
+	nonKey := GetNonKey(allkeys, Middle)
+	proof, err = tree.GetNonMembershipProof(nonKey)
+	require.NoError(t, err)
+	require.True(t, VerifyNonMembership(root, proof, nonKey))
+	require.True(t, VerifyProof(root, proof, nonKey, nil))
+	require.False(t, VerifyNonMembership(root, proof, key))
+	require.False(t, VerifyMembership(root, proof, nonKey, nil))
+
+	// The methods of the tree agree.
+	valid, err := tree.VerifyProof(proof, nonKey)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestVerifyPathToLeaf(t *testing.T) {
+	tree, allkeys, err := BuildTree(200, 0)
+	require.NoError(t, err)
+	root, err := tree.WorkingHash()
+	require.NoError(t, err)
+
+	for _, loc := range []Where{Left, Right, Middle} {
+		key := GetKey(allkeys, loc)
+		path, node, err := tree.root.PathToLeaf(tree.ImmutableTree, key)
+		require.NoError(t, err)
+		valueHash := sha256.Sum256(node.value)
+		leaf := ProofLeafNode{Key: node.key, ValueHash: valueHash[:], Version: node.version}
+
+		require.NoError(t, VerifyPathToLeaf(root, path, leaf, key, node.value))
+		require.ErrorIs(t, VerifyPathToLeaf(root, path, leaf, key, []byte("other")), ErrInvalidProof)
+		require.ErrorIs(t, VerifyPathToLeaf(root, path, leaf, GetNonKey(allkeys, Left), node.value), ErrInvalidProof)
+		require.ErrorIs(t, VerifyPathToLeaf([]byte("root"), path, leaf, key, node.value), ErrInvalidRoot)
+		require.ErrorIs(t, VerifyPathToLeaf(root, path[1:], leaf, key, node.value), ErrInvalidRoot)
+	}
+}
@@ -0,0 +1,77 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofsOfWorkingTree(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("saved"))
+		require.NoError(t, err)
+	}
+	savedHash, _, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	// Speculative changes: an update, an addition and a removal.
+	updated, added, removed := []byte("key010"), []byte("key100"), []byte("key020")
+	_, err = tree.Set(updated, []byte("working"))
+	require.NoError(t, err)
+	_, err = tree.Set(added, []byte("working"))
+	require.NoError(t, err)
+	_, _, err = tree.Remove(removed)
+	require.NoError(t, err)
+
+	workingHash, err := tree.WorkingHash()
+	require.NoError(t, err)
+	require.NotEqual(t, savedHash, workingHash)
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, savedHash, hash)
+
+	for _, key := range [][]byte{updated, added} {
+		proof, err := tree.GetMembershipProof(key)
+		require.NoError(t, err)
+		require.True(t, VerifyMembership(workingHash, proof, key, []byte("working")))
+		require.False(t, VerifyMembership(savedHash, proof, key, []byte("working")))
+		valid, err := tree.VerifyMembership(proof, key)
+		require.NoError(t, err)
+		require.True(t, valid)
+		valid, err = tree.VerifyProof(proof, key)
+		require.NoError(t, err)
+		require.True(t, valid)
+
+		valueProof, err := tree.GetValueProof(key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("working"), []byte(valueProof.Value))
+		require.NoError(t, valueProof.Verify(workingHash))
+
+		batch, err := tree.GetBatchProof([][]byte{key, removed})
+		require.NoError(t, err)
+		valid, err = tree.VerifyBatchProof(batch, [][]byte{key, removed})
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+
+	proof, err := tree.GetNonMembershipProof(removed)
+	require.NoError(t, err)
+	require.True(t, VerifyNonMembership(workingHash, proof, removed))
+	valid, err := tree.VerifyNonMembership(proof, removed)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// The saved version is unaffected, and the proofs still hold once the changes are saved.
+	saved, err := tree.GetVersionedProof(updated, 1)
+	require.NoError(t, err)
+	require.True(t, VerifyMembership(savedHash, saved, updated, []byte("saved")))
+
+	hash, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, workingHash, hash)
+	require.True(t, VerifyNonMembership(hash, proof, removed))
+}
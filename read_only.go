@@ -0,0 +1,197 @@
+package iavl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// readOnlyCacheSize is the node cache size of a ReadOnlyTree, unless the options set
+// NodeCacheBytes or SharedCache.
+const readOnlyCacheSize = 10000
+
+// ErrReadOnly is returned on any attempt to write to the database of a ReadOnlyTree.
+var ErrReadOnly = errors.New("database is opened read-only")
+
+// ErrNeedsMigration is returned by OpenReadOnly and ReadOnlyTree.Refresh if the store has not been upgraded to the
+// latest storage version. Opening it with a MutableTree performs the upgrade.
+var ErrNeedsMigration = errors.New("store needs migration")
+
+// ReadOnlyTree gives access to the saved versions of a tree, e.g. for queries and proofs, while
+// another process writes to the database. It is created by OpenReadOnly and is safe for
+// concurrent use.
+//
+// The versions saved by the writer after the tree is opened are discovered by Refresh. Since the
+// writer updates the fast storage in place, fast nodes are read from the database on each lookup
+// and are only used for keys which have not changed since the version being read.
+type ReadOnlyTree struct {
+	ndb      *nodeDB
+	mtx      sync.Mutex
+	versions map[int64]bool // The available versions.
+	version  int64          // The latest available version.
+}
+
+// OpenReadOnly opens the tree stored in db without ever writing to it: no batch is written, no
+// metadata is updated and the storage is never upgraded. It fails with ErrNeedsMigration if the
+// store has saved versions but its fast storage is missing or out of date.
+func OpenReadOnly(db dbm.DB, opts *Options) (*ReadOnlyTree, error) {
+	ndb := newNodeDB(&readOnlyDB{DB: db}, readOnlyCacheSize, opts)
+	ndb.followsWriter = true
+	tree := &ReadOnlyTree{ndb: ndb}
+	if err := tree.load(); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// Refresh discovers the versions saved and deleted by the writer since the tree was opened or
+// last refreshed, and returns the latest available version. It fails with ErrNeedsMigration,
+// keeping the previously available versions, if the writer has left the fast storage out of date.
+func (tree *ReadOnlyTree) Refresh() (int64, error) {
+	if err := tree.load(); err != nil {
+		return 0, err
+	}
+	return tree.Version(), nil
+}
+
+// load reads the available versions, and checks that the store does not need migration.
+func (tree *ReadOnlyTree) load() error {
+	roots, err := tree.ndb.getRoots()
+	if err != nil {
+		return err
+	}
+	versions := make(map[int64]bool, len(roots))
+	latestVersion := int64(0)
+	for version := range roots {
+		versions[version] = true
+		if version > latestVersion {
+			latestVersion = version
+		}
+	}
+	if latestVersion > 0 {
+		// The storage version is read again, as it is updated by the writer on each commit.
+		storageVersion, err := tree.ndb.db.Get(metadataKeyFormat.Key(unsafeToBz(storageVersionKey)))
+		if err != nil {
+			return err
+		}
+		if err := checkFastStorageVersion(string(storageVersion), latestVersion); err != nil {
+			return err
+		}
+	}
+
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+	tree.versions = versions
+	tree.version = latestVersion
+	return nil
+}
+
+// Version returns the latest available version, or 0 if no version has been saved.
+func (tree *ReadOnlyTree) Version() int64 {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+	return tree.version
+}
+
+// VersionExists returns whether or not a version is available.
+func (tree *ReadOnlyTree) VersionExists(version int64) bool {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+	return tree.versions[version]
+}
+
+// AvailableVersions returns all available versions in ascending order.
+func (tree *ReadOnlyTree) AvailableVersions() []int {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+
+	res := make([]int, 0, len(tree.versions))
+	for version := range tree.versions {
+		res = append(res, int(version))
+	}
+	sort.Ints(res)
+	return res
+}
+
+// GetImmutable loads an ImmutableTree at a given version, for querying and proofs.
+func (tree *ReadOnlyTree) GetImmutable(version int64) (*ImmutableTree, error) {
+	rootHash, err := tree.ndb.getRoot(version)
+	if err != nil {
+		return nil, err
+	}
+	if rootHash == nil {
+		return nil, ErrVersionDoesNotExist
+	}
+
+	t := &ImmutableTree{ndb: tree.ndb, version: version}
+	if len(rootHash) != 0 {
+		t.root, err = tree.ndb.GetNode(rootHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Latest returns the latest available version of the tree, or an empty tree if no version has
+// been saved.
+func (tree *ReadOnlyTree) Latest() (*ImmutableTree, error) {
+	version := tree.Version()
+	if version == 0 {
+		return &ImmutableTree{ndb: tree.ndb}, nil
+	}
+	return tree.GetImmutable(version)
+}
+
+// checkFastStorageVersion returns ErrNeedsMigration unless storageVersion denotes a fast storage
+// up to date with latestVersion. The fast storage may be ahead, if the writer committed a new
+// version after latestVersion was read.
+func checkFastStorageVersion(storageVersion string, latestVersion int64) error {
+	if storageVersion < fastStorageVersionValue {
+		return fmt.Errorf("%w: storage version %q is not fast storage", ErrNeedsMigration, storageVersion)
+	}
+	versions := strings.Split(storageVersion, fastStorageVersionDelimiter)
+	if len(versions) == 2 {
+		fastVersion, err := strconv.ParseInt(versions[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid fast storage version %q, %w", storageVersion, err)
+		}
+		if fastVersion < latestVersion {
+			return fmt.Errorf("%w: fast storage version %q is behind latest version %d",
+				ErrNeedsMigration, storageVersion, latestVersion)
+		}
+	}
+	return nil
+}
+
+// readOnlyDB wraps a database, failing all writes with ErrReadOnly.
+type readOnlyDB struct {
+	dbm.DB
+}
+
+var _ dbm.DB = (*readOnlyDB)(nil)
+
+func (*readOnlyDB) Set([]byte, []byte) error     { return ErrReadOnly }
+func (*readOnlyDB) SetSync([]byte, []byte) error { return ErrReadOnly }
+func (*readOnlyDB) Delete([]byte) error          { return ErrReadOnly }
+func (*readOnlyDB) DeleteSync([]byte) error      { return ErrReadOnly }
+func (*readOnlyDB) NewBatch() dbm.Batch          { return readOnlyBatch{} }
+
+// Close does not close the wrapped database, which is owned by the caller.
+func (*readOnlyDB) Close() error { return nil }
+
+// readOnlyBatch is the batch of a readOnlyDB, failing all writes with ErrReadOnly.
+type readOnlyBatch struct{}
+
+var _ dbm.Batch = readOnlyBatch{}
+
+func (readOnlyBatch) Set([]byte, []byte) error { return ErrReadOnly }
+func (readOnlyBatch) Delete([]byte) error      { return ErrReadOnly }
+func (readOnlyBatch) Write() error             { return ErrReadOnly }
+func (readOnlyBatch) WriteSync() error         { return ErrReadOnly }
+func (readOnlyBatch) Close() error             { return nil }
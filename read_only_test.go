@@ -0,0 +1,202 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCountingDB counts the writes reaching a database.
+type writeCountingDB struct {
+	db.DB
+	writes int
+}
+
+func (d *writeCountingDB) Set(key, value []byte) error {
+	d.writes++
+	return d.DB.Set(key, value)
+}
+
+func (d *writeCountingDB) SetSync(key, value []byte) error {
+	d.writes++
+	return d.DB.SetSync(key, value)
+}
+
+func (d *writeCountingDB) Delete(key []byte) error {
+	d.writes++
+	return d.DB.Delete(key)
+}
+
+func (d *writeCountingDB) DeleteSync(key []byte) error {
+	d.writes++
+	return d.DB.DeleteSync(key)
+}
+
+func (d *writeCountingDB) NewBatch() db.Batch {
+	d.writes++
+	return d.DB.NewBatch()
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("val%d", i)))
+		require.NoError(t, err)
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+	hash, err := tree.Hash()
+	require.NoError(t, err)
+
+	countingDB := &writeCountingDB{DB: memDB}
+	roTree, err := OpenReadOnly(countingDB, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, roTree.Version())
+	require.Equal(t, []int{1, 2, 3}, roTree.AvailableVersions())
+	require.True(t, roTree.VersionExists(2))
+	require.False(t, roTree.VersionExists(4))
+
+	itree, err := roTree.GetImmutable(3)
+	require.NoError(t, err)
+	roHash, err := itree.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, roHash)
+	value, err := itree.Get([]byte("key2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("val2"), value)
+	_, err = itree.GetMembershipProof([]byte("key1"))
+	require.NoError(t, err)
+
+	itree, err = roTree.GetImmutable(1)
+	require.NoError(t, err)
+	value, err = itree.Get([]byte("key2"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	_, err = roTree.GetImmutable(4)
+	require.ErrorIs(t, err, ErrVersionDoesNotExist)
+
+	// Writes through the node database are rejected.
+	require.ErrorIs(t, roTree.ndb.saveRoot([]byte{1}, 4), ErrReadOnly)
+	require.ErrorIs(t, roTree.ndb.Commit(), ErrReadOnly)
+	require.Zero(t, countingDB.writes)
+}
+
+func TestOpenReadOnly_Empty(t *testing.T) {
+	roTree, err := OpenReadOnly(db.NewMemDB(), nil)
+	require.NoError(t, err)
+	require.Zero(t, roTree.Version())
+	require.Empty(t, roTree.AvailableVersions())
+}
+
+func TestOpenReadOnly_NeedsMigration(t *testing.T) {
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, true)
+	require.NoError(t, err)
+	_, err = tree.Set([]byte("key"), []byte("value"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+
+	countingDB := &writeCountingDB{DB: memDB}
+	_, err = OpenReadOnly(countingDB, nil)
+	require.ErrorIs(t, err, ErrNeedsMigration)
+	require.Zero(t, countingDB.writes)
+
+	// Once upgraded by a writer, the store can be opened.
+	tree, err = NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	_, err = OpenReadOnly(countingDB, nil)
+	require.NoError(t, err)
+
+	// A fast storage left behind by a writer skipping the upgrade also needs migration.
+	tree, err = NewMutableTree(memDB, 0, true)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	_, err = tree.Set([]byte("key"), []byte("other value"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	_, err = OpenReadOnly(countingDB, nil)
+	require.ErrorIs(t, err, ErrNeedsMigration)
+	require.Zero(t, countingDB.writes)
+}
+
+func TestReadOnlyTree_Refresh(t *testing.T) {
+	memDB := db.NewMemDB()
+	tree, err := NewMutableTree(memDB, 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("val%d", i)))
+		require.NoError(t, err)
+		_, _, err = tree.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	roTree, err := OpenReadOnly(memDB, nil)
+	require.NoError(t, err)
+	old, err := roTree.Latest()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, old.Version())
+	value, err := old.Get([]byte("key1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("val1"), value)
+
+	// The writer saves a new version and deletes the first one.
+	_, err = tree.Set([]byte("key1"), []byte("new1"))
+	require.NoError(t, err)
+	_, _, err = tree.Remove([]byte("key2"))
+	require.NoError(t, err)
+	_, err = tree.Set([]byte("key3"), []byte("val3"))
+	require.NoError(t, err)
+	hash, version, err := tree.SaveVersion()
+	require.NoError(t, err)
+	require.NoError(t, tree.DeleteVersion(1))
+
+	// The new version is not visible until refreshed.
+	require.EqualValues(t, 3, roTree.Version())
+	latest, err := roTree.Latest()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, latest.Version())
+
+	refreshed, err := roTree.Refresh()
+	require.NoError(t, err)
+	require.Equal(t, version, refreshed)
+	require.Equal(t, []int{2, 3, 4}, roTree.AvailableVersions())
+	require.False(t, roTree.VersionExists(1))
+	_, err = roTree.GetImmutable(1)
+	require.ErrorIs(t, err, ErrVersionDoesNotExist)
+
+	latest, err = roTree.Latest()
+	require.NoError(t, err)
+	latestHash, err := latest.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hash, latestHash)
+	for key, expected := range map[string][]byte{"key0": []byte("val0"), "key1": []byte("new1"), "key2": nil, "key3": []byte("val3")} {
+		value, err := latest.Get([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, expected, value, key)
+	}
+
+	// The previous version still reads its own state, although the writer has updated the
+	// fast storage since.
+	for key, expected := range map[string][]byte{"key0": []byte("val0"), "key1": []byte("val1"), "key2": []byte("val2"), "key3": nil} {
+		value, err := old.Get([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, expected, value, key)
+	}
+	var keys []string
+	_, err = old.Iterate(func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"key0", "key1", "key2"}, keys)
+}
@@ -0,0 +1,62 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// LeafProof proves a leaf of a tree with the path from the root to it. As the size of each inner
+// node is committed into its hash, the path also proves the index of the leaf in the tree, and
+// the size of the tree.
+type LeafProof struct {
+	Path PathToLeaf    `json:"path"`
+	Leaf ProofLeafNode `json:"leaf"`
+}
+
+// Index verifies that proof, as returned by ImmutableTree.GetIndexProof, proves that key is the
+// key at index in the tree with the given root hash, with value. It returns an error wrapping
+// ErrInvalidProof or ErrInvalidRoot if it does not.
+func Index(rootHash []byte, index int64, key, value []byte, proof *LeafProof) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil index proof", ErrInvalidProof)
+	}
+	if err := Leaf(rootHash, proof.Path, proof.Leaf, key, value); err != nil {
+		return err
+	}
+	if idx := proof.Path.Index(); idx != index {
+		return fmt.Errorf("%w: key %X is at index %d, expected %d", ErrInvalidProof, key, idx, index)
+	}
+	return nil
+}
+
+// Size verifies that proof, as returned by ImmutableTree.GetSizeProof, proves that the tree with
+// the given root hash has size keys. The empty tree needs no proof. It returns an error wrapping
+// ErrInvalidProof or ErrInvalidRoot if it does not.
+func Size(rootHash []byte, size int64, proof *LeafProof) error {
+	if size == 0 {
+		emptyHash := sha256.Sum256(nil)
+		if !bytes.Equal(rootHash, emptyHash[:]) {
+			return fmt.Errorf("%w: the tree is not empty", ErrInvalidRoot)
+		}
+		return nil
+	}
+	if proof == nil {
+		return fmt.Errorf("%w: nil size proof", ErrInvalidProof)
+	}
+	if err := verifyLeafRoot(rootHash, proof.Path, proof.Leaf); err != nil {
+		return err
+	}
+	if s := proof.size(); s != size {
+		return fmt.Errorf("%w: the tree has %d keys, expected %d", ErrInvalidProof, s, size)
+	}
+	return nil
+}
+
+// size returns the size of the tree, committed into the root of the path.
+func (proof *LeafProof) size() int64 {
+	if len(proof.Path) == 0 {
+		return 1 // The root is the leaf.
+	}
+	return proof.Path[0].Size
+}
@@ -0,0 +1,172 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// RangeProof proves the complete set of key/value pairs of a tree in a range of keys.
+//
+// Its leaves are the contiguous leaves of the tree holding the keys in range, possibly preceded by
+// the leaf of the last key before the range, and followed by the leaf of the first key at or after
+// its end. These boundary leaves prove that no key was omitted before or after the range, unless
+// the range starts at the left-most leaf of the tree or ends at its right-most one.
+//
+// LeftPath is the path from the root to the first leaf. The path to each of the following leaves
+// shares its upper part with the path to the previous one, so that InnerNodes[i] only holds the
+// inner nodes between the node where they diverge and the leaf i+1. All of these go left, as the
+// leaf i+1 is the left-most leaf of the right subtree of that node.
+type RangeProof struct {
+	LeftPath   PathToLeaf      `json:"left_path"`
+	InnerNodes []PathToLeaf    `json:"inner_nodes"`
+	Leaves     []ProofLeafNode `json:"leaves"`
+}
+
+// Range verifies that keys and values, as returned by ImmutableTree.GetRangeProof with the same
+// start, end and limit, are all the key/value pairs in the range [start, end) of the tree with
+// the given root hash. It returns an error wrapping ErrInvalidProof or ErrInvalidRoot if they
+// are not.
+func Range(rootHash, start, end []byte, limit int, keys, values [][]byte, proof *RangeProof) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("%w: got %d keys and %d values", ErrInvalidInputs, len(keys), len(values))
+	}
+	if proof == nil {
+		return fmt.Errorf("%w: nil range proof", ErrInvalidProof)
+	}
+
+	if len(proof.Leaves) == 0 {
+		// Only the empty tree has no leaves.
+		emptyHash := sha256.Sum256(nil)
+		if !bytes.Equal(rootHash, emptyHash[:]) {
+			return fmt.Errorf("%w: range proof without leaves for a non-empty tree", ErrInvalidRoot)
+		}
+		if len(keys) > 0 {
+			return fmt.Errorf("%w: got %d keys for an empty tree", ErrInvalidProof, len(keys))
+		}
+		return nil
+	}
+
+	root, leftmost, rightmost, err := proof.computeRootHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, rootHash) {
+		return fmt.Errorf("%w: range proof root hash %X, expected %X", ErrInvalidRoot, root, rootHash)
+	}
+
+	leaves := proof.Leaves
+	switch {
+	case start != nil && bytes.Compare(leaves[0].Key, start) < 0:
+		leaves = leaves[1:]
+	case !leftmost:
+		return fmt.Errorf("%w: the first leaf is neither before the range nor the left-most leaf", ErrInvalidProof)
+	}
+	n := len(leaves)
+	switch {
+	case n > 0 && end != nil && bytes.Compare(leaves[n-1].Key, end) >= 0:
+		leaves = leaves[:n-1]
+	case limit > 0 && n == limit, rightmost:
+	default:
+		return fmt.Errorf("%w: the last leaf is neither after the range nor the right-most leaf", ErrInvalidProof)
+	}
+	if limit > 0 && len(leaves) > limit {
+		return fmt.Errorf("%w: %d keys in range exceed the limit of %d", ErrInvalidProof, len(leaves), limit)
+	}
+
+	if len(leaves) != len(keys) {
+		return fmt.Errorf("%w: the proof has %d keys in range, got %d", ErrInvalidProof, len(leaves), len(keys))
+	}
+	for i, leaf := range leaves {
+		if (start != nil && bytes.Compare(leaf.Key, start) < 0) || (end != nil && bytes.Compare(leaf.Key, end) >= 0) {
+			return fmt.Errorf("%w: key %X is out of range", ErrInvalidProof, []byte(leaf.Key))
+		}
+		if !bytes.Equal(leaf.Key, keys[i]) {
+			return fmt.Errorf("%w: key %X in range, got %X", ErrInvalidProof, []byte(leaf.Key), keys[i])
+		}
+		valueHash := sha256.Sum256(values[i])
+		if !bytes.Equal(leaf.ValueHash, valueHash[:]) {
+			return fmt.Errorf("%w: value hash mismatch for key %X", ErrInvalidProof, keys[i])
+		}
+	}
+	return nil
+}
+
+// computeRootHash computes the root hash of the tree from the leaves of the proof, which must be
+// sorted. It also returns whether the first leaf is the left-most leaf of the tree, and the last
+// leaf its right-most one.
+func (proof *RangeProof) computeRootHash() (rootHash []byte, leftmost, rightmost bool, err error) {
+	if len(proof.InnerNodes) != len(proof.Leaves)-1 {
+		return nil, false, false, fmt.Errorf("%w: %d inner paths for %d leaves",
+			ErrInvalidProof, len(proof.InnerNodes), len(proof.Leaves))
+	}
+
+	// path is the path from the root to the current leaf. Each node going right holds the hash of
+	// its left child, computed from the previous leaves if they are part of the proof.
+	path := make(PathToLeaf, 0, len(proof.LeftPath))
+	leftmost = true
+	for _, pin := range proof.LeftPath {
+		if err := checkProofInnerNode(pin); err != nil {
+			return nil, false, false, err
+		}
+		leftmost = leftmost && len(pin.Left) == 0
+		path = append(path, pin)
+	}
+
+	hash, err := proof.Leaves[0].Hash()
+	if err != nil {
+		return nil, false, false, err
+	}
+	for i, leaf := range proof.Leaves[1:] {
+		if bytes.Compare(proof.Leaves[i].Key, leaf.Key) >= 0 {
+			return nil, false, false, fmt.Errorf("%w: leaves are not sorted", ErrInvalidProof)
+		}
+
+		// Go up to the last node where the path went left, whose right subtree holds the next leaf.
+		d := len(path) - 1
+		for ; d >= 0 && len(path[d].Left) > 0; d-- {
+			hash, err = path[d].Hash(hash)
+			if err != nil {
+				return nil, false, false, err
+			}
+		}
+		if d < 0 {
+			return nil, false, false, fmt.Errorf("%w: leaf %d is after the right-most leaf", ErrInvalidProof, i+1)
+		}
+		pin := path[d]
+		pin.Left, pin.Right = hash, nil
+		path = append(path[:d], pin)
+
+		for _, pin := range proof.InnerNodes[i] {
+			if err := checkProofInnerNode(pin); err != nil {
+				return nil, false, false, err
+			}
+			if len(pin.Left) > 0 {
+				return nil, false, false, fmt.Errorf("%w: inner node on the path to leaf %d goes right", ErrInvalidProof, i+1)
+			}
+			path = append(path, pin)
+		}
+		hash, err = leaf.Hash()
+		if err != nil {
+			return nil, false, false, err
+		}
+	}
+
+	rightmost = true
+	for d := len(path) - 1; d >= 0; d-- {
+		rightmost = rightmost && len(path[d].Right) == 0
+		hash, err = path[d].Hash(hash)
+		if err != nil {
+			return nil, false, false, err
+		}
+	}
+	return hash, leftmost, rightmost, nil
+}
+
+// checkProofInnerNode checks that exactly one of the child hashes of pin is set.
+func checkProofInnerNode(pin ProofInnerNode) error {
+	if (len(pin.Left) > 0) == (len(pin.Right) > 0) {
+		return fmt.Errorf("%w: inner node must have exactly one child hash", ErrInvalidProof)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package verify verifies IAVL proofs against a trusted root hash, without a tree or a database.
+//
+// It only depends on the ics23 package and on the standard-library-only internal/bytes and
+// internal/encoding packages of this module, so that light clients and other verifier binaries
+// can use it without pulling the storage dependencies of the iavl package. It verifies the ics23
+// proofs returned by the iavl package, as well as its native proofs: the paths to leaves returned
+// by Node.PathToLeaf, and the range proofs returned by ImmutableTree.GetRangeProof.
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	ics23 "github.com/confio/ics23/go"
+)
+
+// Membership returns true iff proof is an ics23 existence proof of key with value in the tree
+// with the given root hash.
+func Membership(rootHash []byte, proof *ics23.CommitmentProof, key, value []byte) bool {
+	return ics23.VerifyMembership(ics23.IavlSpec, rootHash, proof, key, value)
+}
+
+// NonMembership returns true iff proof is an ics23 non-existence proof of key in the tree with
+// the given root hash.
+func NonMembership(rootHash []byte, proof *ics23.CommitmentProof, key []byte) bool {
+	return ics23.VerifyNonMembership(ics23.IavlSpec, rootHash, proof, key)
+}
+
+// Leaf verifies that leaf, with the path from the root to it, proves that key exists with value
+// in the tree with the given root hash. It returns an error wrapping ErrInvalidProof or
+// ErrInvalidRoot if it does not.
+func Leaf(rootHash []byte, path PathToLeaf, leaf ProofLeafNode, key, value []byte) error {
+	if !bytes.Equal(leaf.Key, key) {
+		return fmt.Errorf("%w: leaf key %X, expected %X", ErrInvalidProof, []byte(leaf.Key), key)
+	}
+	valueHash := sha256.Sum256(value)
+	if !bytes.Equal(leaf.ValueHash, valueHash[:]) {
+		return fmt.Errorf("%w: value hash mismatch for key %X", ErrInvalidProof, key)
+	}
+
+	return verifyLeafRoot(rootHash, path, leaf)
+}
+
+// verifyLeafRoot verifies that the root hash computed from leaf, with the path from the root to
+// it, is rootHash.
+func verifyLeafRoot(rootHash []byte, path PathToLeaf, leaf ProofLeafNode) error {
+	hash, err := leaf.Hash()
+	if err != nil {
+		return err
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if err := checkProofInnerNode(path[i]); err != nil {
+			return err
+		}
+		hash, err = path[i].Hash(hash)
+		if err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(hash, rootHash) {
+		return fmt.Errorf("%w: proof root hash %X, expected %X", ErrInvalidRoot, hash, rootHash)
+	}
+	return nil
+}
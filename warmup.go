@@ -0,0 +1,234 @@
+package iavl
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cosmos/iavl/internal/encoding"
+)
+
+// recentKeysKey is the metadata entry holding the most recently used keys, recorded by
+// MutableTree.Close for the cache warm-up.
+const recentKeysKey = "recent_keys"
+
+// warmUpProgressInterval is the number of nodes or keys loaded between calls of the progress callback.
+const warmUpProgressInterval = 1000
+
+// errWarmUpStopped is returned by a warm-up interrupted by MutableTree.Close.
+var errWarmUpStopped = errors.New("warm-up stopped")
+
+// WarmUpOptions configures the warm-up of the node caches after a tree is loaded by LoadVersion
+// or LazyLoadVersion. The warm-up runs in the background, and the tree can be used meanwhile.
+type WarmUpOptions struct {
+	// Levels is the number of levels of the loaded tree, starting from the root, whose nodes
+	// are preloaded into the node cache.
+	Levels int
+
+	// RecentKeys is the number of most recently used keys to track. They are recorded in the
+	// database by MutableTree.Close, and the fast nodes and paths of those still in the tree
+	// are preloaded on the next load.
+	RecentKeys int
+
+	// Progress, if not nil, is called periodically from the warm-up goroutine, and a last time
+	// once the warm-up is done.
+	Progress func(WarmUpProgress)
+}
+
+// WarmUpProgress reports the progress of a cache warm-up.
+type WarmUpProgress struct {
+	Nodes     int   // Number of nodes of the top levels loaded so far.
+	Keys      int   // Number of recently used keys loaded so far.
+	TotalKeys int   // Number of recently used keys to load.
+	Done      bool  // Whether the warm-up is done.
+	Err       error // The error which ended the warm-up, if any.
+}
+
+// warmUp is a cache warm-up running in the background.
+type warmUp struct {
+	done chan struct{}
+	stop chan struct{}
+	err  error
+}
+
+// stopAndWait interrupts the warm-up and returns its error, if it failed before being stopped.
+func (w *warmUp) stopAndWait() error {
+	close(w.stop)
+	<-w.done
+	if errors.Is(w.err, errWarmUpStopped) {
+		return nil
+	}
+	return w.err
+}
+
+// recentKeys tracks the most recently used keys of a tree. It is safe for concurrent use.
+type recentKeys struct {
+	mtx  sync.Mutex
+	max  int
+	dict map[string]*list.Element
+	ll   *list.List // LRU queue of keys.
+}
+
+func newRecentKeys(max int) *recentKeys {
+	return &recentKeys{
+		max:  max,
+		dict: make(map[string]*list.Element),
+		ll:   list.New(),
+	}
+}
+
+// touch marks key as the most recently used one.
+func (r *recentKeys) touch(key []byte) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if e, exists := r.dict[unsafeToStr(key)]; exists {
+		r.ll.MoveToFront(e)
+		return
+	}
+	keyStr := string(key)
+	r.dict[keyStr] = r.ll.PushFront(keyStr)
+	if r.ll.Len() > r.max {
+		delete(r.dict, r.ll.Remove(r.ll.Back()).(string))
+	}
+}
+
+// encode returns the keys, most recently used first, as a sequence of length-prefixed byte slices.
+func (r *recentKeys) encode() ([]byte, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	buf := bytes.NewBuffer([]byte{})
+	for e := r.ll.Front(); e != nil; e = e.Next() {
+		if err := encoding.EncodeBytes(buf, unsafeToBz(e.Value.(string))); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeRecentKeys(bz []byte) ([][]byte, error) {
+	var keys [][]byte
+	for len(bz) > 0 {
+		key, n, err := encoding.DecodeBytes(bz)
+		if err != nil {
+			return nil, fmt.Errorf("decoding recent keys, %w", err)
+		}
+		keys = append(keys, key)
+		bz = bz[n:]
+	}
+	return keys, nil
+}
+
+// startWarmUp preloads the caches for the tree t in the background, as configured by opts.
+func startWarmUp(t *ImmutableTree, opts *WarmUpOptions) *warmUp {
+	w := &warmUp{
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		start := time.Now()
+		var progress WarmUpProgress
+		w.err = w.run(t, opts, &progress)
+		switch {
+		case w.err == nil:
+			t.ndb.logger.Info("cache warm-up done", "version", t.version, "nodes", progress.Nodes,
+				"keys", progress.Keys, "duration", time.Since(start))
+		case !errors.Is(w.err, errWarmUpStopped):
+			t.ndb.logger.Error("cache warm-up failed", "version", t.version, "err", w.err)
+		}
+		if opts.Progress != nil {
+			progress.Done = true
+			progress.Err = w.err
+			opts.Progress(progress)
+		}
+	}()
+	return w
+}
+
+func (w *warmUp) run(t *ImmutableTree, opts *WarmUpOptions, progress *WarmUpProgress) error {
+	// step is called after each node or key is loaded, reporting progress and checking
+	// whether the warm-up has been stopped.
+	count := 0
+	step := func() error {
+		select {
+		case <-w.stop:
+			return errWarmUpStopped
+		default:
+		}
+		count++
+		if opts.Progress != nil && count%warmUpProgressInterval == 0 {
+			opts.Progress(*progress)
+		}
+		return nil
+	}
+
+	// Load the top levels breadth first, the root being loaded already.
+	level := []*Node{t.root}
+	for depth := 1; depth < opts.Levels && len(level) > 0; depth++ {
+		var next []*Node
+		for _, node := range level {
+			if node == nil || node.isLeaf() {
+				continue
+			}
+			for _, hash := range [][]byte{node.leftHash, node.rightHash} {
+				child, err := t.ndb.GetNode(hash)
+				if err != nil {
+					return fmt.Errorf("warming up level %d, %w", depth, err)
+				}
+				next = append(next, child)
+				progress.Nodes++
+				if err := step(); err != nil {
+					return err
+				}
+			}
+		}
+		level = next
+	}
+
+	if opts.RecentKeys <= 0 || t.root == nil {
+		return nil
+	}
+	bz, err := t.ndb.db.Get(metadataKeyFormat.Key([]byte(recentKeysKey)))
+	if err != nil {
+		return fmt.Errorf("reading recent keys, %w", err)
+	}
+	keys, err := decodeRecentKeys(bz)
+	if err != nil {
+		return err
+	}
+	if len(keys) > opts.RecentKeys {
+		keys = keys[:opts.RecentKeys]
+	}
+	progress.TotalKeys = len(keys)
+
+	// Keep tracking the loaded keys, so that they are recorded again on close unless
+	// other keys are used in the meantime.
+	if t.ndb.recentKeys != nil {
+		for i := len(keys) - 1; i >= 0; i-- {
+			t.ndb.recentKeys.touch(keys[i])
+		}
+	}
+
+	fastStorage := !t.skipFastStorageUpgrade && t.ndb.hasUpgradedToFastStorage()
+	for _, key := range keys {
+		// The path to the key is loaded even if the key has been removed since.
+		if _, _, err := t.root.get(t, key); err != nil {
+			return fmt.Errorf("warming up key %X, %w", key, err)
+		}
+		if fastStorage {
+			if _, err := t.ndb.GetFastNode(key); err != nil {
+				return fmt.Errorf("warming up fast node %X, %w", key, err)
+			}
+		}
+		progress.Keys++
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentKeys(t *testing.T) {
+	r := newRecentKeys(3)
+	for _, key := range []string{"a", "b", "c", "a", "d"} {
+		r.touch([]byte(key))
+	}
+	bz, err := r.encode()
+	require.NoError(t, err)
+	keys, err := decodeRecentKeys(bz)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("d"), []byte("a"), []byte("c")}, keys)
+
+	_, err = decodeRecentKeys([]byte{0x05, 'a'})
+	require.Error(t, err)
+}
+
+func setupWarmUpTree(t *testing.T, memDB db.DB, opts *Options) *MutableTree {
+	tree, err := NewMutableTreeWithOpts(memDB, 100000, opts, false)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		_, err = tree.Set(key, key)
+		require.NoError(t, err)
+	}
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	return tree
+}
+
+func TestWarmUp_Levels(t *testing.T) {
+	memDB := db.NewMemDB()
+	setupWarmUpTree(t, memDB, nil)
+
+	for _, lazy := range []bool{false, true} {
+		var progress []WarmUpProgress
+		opts := DefaultOptions()
+		opts.WarmUp = &WarmUpOptions{
+			Levels:   4,
+			Progress: func(p WarmUpProgress) { progress = append(progress, p) },
+		}
+		tree, err := NewMutableTreeWithOpts(memDB, 100000, &opts, false)
+		require.NoError(t, err)
+		if lazy {
+			_, err = tree.LazyLoadVersion(0)
+		} else {
+			_, err = tree.Load()
+		}
+		require.NoError(t, err)
+		require.NoError(t, tree.WaitForWarmUp())
+
+		// The root is loaded by the load itself, the warm-up loads the 3 levels below.
+		require.Equal(t, 1+2+4+8, tree.ndb.nodeCache.Len())
+		require.Equal(t, []WarmUpProgress{{Nodes: 2 + 4 + 8, Done: true}}, progress)
+		require.NoError(t, tree.Close())
+	}
+}
+
+func TestWarmUp_RecentKeys(t *testing.T) {
+	memDB := db.NewMemDB()
+	opts := DefaultOptions()
+	opts.WarmUp = &WarmUpOptions{RecentKeys: 10}
+	tree := setupWarmUpTree(t, memDB, &opts)
+
+	itree, err := tree.GetImmutable(tree.Version())
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err = itree.Get([]byte(fmt.Sprintf("key%04d", i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tree.Close())
+
+	var last WarmUpProgress
+	opts.WarmUp.Progress = func(p WarmUpProgress) { last = p }
+	tree, err = NewMutableTreeWithOpts(memDB, 100000, &opts, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	require.NoError(t, tree.WaitForWarmUp())
+	require.Equal(t, WarmUpProgress{Keys: 10, TotalKeys: 10, Done: true}, last)
+
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		require.Equal(t, i >= 10, tree.ndb.fastNodeCache.Has(key), "key %s", key)
+	}
+	// The whole path to each key is cached.
+	stat := &Statistics{}
+	tree.ndb.metrics = stat
+	for i := 10; i < 20; i++ {
+		_, _, err = tree.GetWithIndex([]byte(fmt.Sprintf("key%04d", i)))
+		require.NoError(t, err)
+	}
+	require.Zero(t, stat.GetCacheMissCnt())
+	require.NoError(t, tree.Close())
+}
+
+func TestWarmUp_Close(t *testing.T) {
+	memDB := db.NewMemDB()
+	setupWarmUpTree(t, memDB, nil)
+
+	opts := DefaultOptions()
+	opts.WarmUp = &WarmUpOptions{Levels: 64}
+	tree, err := NewMutableTreeWithOpts(memDB, 100000, &opts, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+
+	// The tree can be used while the warm-up is running.
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		_, err = tree.Set(key, []byte("updated"))
+		require.NoError(t, err)
+	}
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+
+	// Closing while the warm-up may still be running interrupts it without error.
+	require.NoError(t, tree.Close())
+	require.NoError(t, tree.WaitForWarmUp())
+}
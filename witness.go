@@ -0,0 +1,357 @@
+package iavl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cosmos/iavl/internal/encoding"
+)
+
+// Witness holds the nodes of a saved version of a tree that were touched while reading or
+// changing the tree, e.g. when executing a block. It is a multi-proof of these nodes against the
+// root hash of the version: every node is reachable from the root through the hashes of its
+// ancestors. The nodes created by the changes are not part of it, as replaying the changes on the
+// witnessed nodes with a PartialTree recreates them, without the rest of the tree.
+type Witness struct {
+	// Version is the version of the tree the nodes belong to.
+	Version int64
+	// RootHash is the root hash of the version.
+	RootHash []byte
+	// Nodes are the encodings of the nodes, sorted by hash.
+	Nodes [][]byte
+}
+
+// witnessRecorder records the nodes of a saved version touched by a tree.
+type witnessRecorder struct {
+	version  int64
+	rootHash []byte
+	nodes    map[string]*Node
+}
+
+// record records node if it belongs to the recorded version.
+func (w *witnessRecorder) record(node *Node) {
+	if node == nil || !node.persisted || node.version > w.version {
+		return
+	}
+	w.nodes[unsafeToStr(node.hash)] = node
+}
+
+// StartWitness starts recording a witness of the nodes of the last saved version touched by the
+// following reads and changes of the working tree, until StopWitness is called. It returns an
+// error if a witness is already being recorded or if the working tree has unsaved changes.
+//
+// While recording, reads traverse the tree rather than use fast storage, so that the nodes read
+// are recorded too. Reads of changed keys then touch the same nodes as they would when replaying
+// the changes on the witness.
+//
+// The recording carries on through SaveVersion and Rollback, so that a witness can span several
+// versions, while loading a version stops it.
+func (tree *MutableTree) StartWitness() error {
+	if tree.witness != nil {
+		return errors.New("a witness is already being recorded")
+	}
+	if tree.root != tree.lastSaved.root {
+		return errors.New("cannot record a witness of a tree with unsaved changes")
+	}
+	rootHash, err := tree.WorkingHash()
+	if err != nil {
+		return err
+	}
+	tree.witness = &witnessRecorder{
+		version:  tree.version,
+		rootHash: rootHash,
+		nodes:    make(map[string]*Node),
+	}
+	tree.witness.record(tree.root)
+	return nil
+}
+
+// StopWitness stops recording the witness started by StartWitness, and returns it.
+func (tree *MutableTree) StopWitness() (*Witness, error) {
+	recorder := tree.witness
+	if recorder == nil {
+		return nil, errors.New("no witness is being recorded")
+	}
+	tree.witness = nil
+
+	nodes, err := encodeNodes(recorder.nodes)
+	if err != nil {
+		return nil, err
+	}
+	return &Witness{
+		Version:  recorder.version,
+		RootHash: recorder.rootHash,
+		Nodes:    nodes,
+	}, nil
+}
+
+// encodeNodes returns the encodings of nodes, sorted by hash.
+func encodeNodes(nodes map[string]*Node) ([][]byte, error) {
+	hashes := make([]string, 0, len(nodes))
+	for hash := range nodes {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	encoded := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		var buf bytes.Buffer
+		if err := nodes[hash].writeBytes(&buf); err != nil {
+			return nil, err
+		}
+		encoded[i] = buf.Bytes()
+	}
+	return encoded, nil
+}
+
+// Verify verifies that the witness is a multi-proof of its nodes against rootHash. It returns an
+// error wrapping ErrInvalidRoot if the witness is of another root hash, and ErrInvalidProof if a
+// node cannot be decoded or is not reachable from the root.
+func (w *Witness) Verify(rootHash []byte) error {
+	_, err := w.verifiedNodes(rootHash)
+	return err
+}
+
+// verifiedNodes verifies the witness against rootHash like Verify, and returns its nodes by hash.
+func (w *Witness) verifiedNodes(rootHash []byte) (map[string]*Node, error) {
+	if !bytes.Equal(w.RootHash, rootHash) {
+		return nil, fmt.Errorf("%w: witness root hash %X, expected %X", ErrInvalidRoot, w.RootHash, rootHash)
+	}
+	nodes, err := w.nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	reached := 0
+	var reach func(hash []byte)
+	reach = func(hash []byte) {
+		node, ok := nodes[unsafeToStr(hash)]
+		if !ok {
+			return
+		}
+		reached++
+		if !node.isLeaf() {
+			reach(node.leftHash)
+			reach(node.rightHash)
+		}
+	}
+	reach(rootHash)
+	if reached != len(nodes) {
+		return nil, fmt.Errorf("%w: %d of the %d witness nodes are not reachable from the root",
+			ErrInvalidProof, len(nodes)-reached, len(nodes))
+	}
+	return nodes, nil
+}
+
+// nodes decodes the nodes of the witness, by hash.
+func (w *Witness) nodes() (map[string]*Node, error) {
+	nodes := make(map[string]*Node, len(w.Nodes))
+	for _, bz := range w.Nodes {
+		node, err := MakeNode(bz)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+		hash, err := node._hash()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := nodes[string(hash)]; ok {
+			return nil, fmt.Errorf("%w: duplicate witness node %X", ErrInvalidProof, hash)
+		}
+		nodes[string(hash)] = node
+	}
+	return nodes, nil
+}
+
+// Flags of an inner node in the binary encoding of a Witness, telling which children follow it.
+const (
+	witnessLeftChild  = 1 << 0
+	witnessRightChild = 1 << 1
+)
+
+/*
+MarshalBinary implements encoding.BinaryMarshaler. The witness must be valid, see Verify.
+
+The encoding is deterministic and compact: the hashes of the witnessed nodes are left out, as they
+can be recomputed from the nodes. It is the version, the root hash and the number of nodes,
+followed by the nodes in pre-order from the root. Each node is encoded as its height, version and
+key, followed by the value of a leaf, or the size of an inner node and a byte of flags telling
+which of its children are witnessed. The hashes of the other children follow, then the witnessed
+children themselves.
+*/
+func (w *Witness) MarshalBinary() ([]byte, error) {
+	nodes, err := w.verifiedNodes(w.RootHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var encode func(node *Node) error
+	encode = func(node *Node) error {
+		err := encoding.EncodeVarint(&buf, int64(node.subtreeHeight))
+		if err == nil {
+			err = encoding.EncodeVarint(&buf, node.version)
+		}
+		if err == nil {
+			err = encoding.EncodeBytes(&buf, node.key)
+		}
+		if err != nil {
+			return err
+		}
+		if node.isLeaf() {
+			return encoding.EncodeBytes(&buf, node.value)
+		}
+
+		if err := encoding.EncodeVarint(&buf, node.size); err != nil {
+			return err
+		}
+		left, right := nodes[unsafeToStr(node.leftHash)], nodes[unsafeToStr(node.rightHash)]
+		var flags byte
+		if left != nil {
+			flags |= witnessLeftChild
+		}
+		if right != nil {
+			flags |= witnessRightChild
+		}
+		buf.WriteByte(flags)
+		if left == nil {
+			if err := encoding.EncodeBytes(&buf, node.leftHash); err != nil {
+				return err
+			}
+		}
+		if right == nil {
+			if err := encoding.EncodeBytes(&buf, node.rightHash); err != nil {
+				return err
+			}
+		}
+		if left != nil {
+			if err := encode(left); err != nil {
+				return err
+			}
+		}
+		if right != nil {
+			return encode(right)
+		}
+		return nil
+	}
+
+	err = encoding.EncodeVarint(&buf, w.Version)
+	if err == nil {
+		err = encoding.EncodeBytes(&buf, w.RootHash)
+	}
+	if err == nil {
+		err = encoding.EncodeUvarint(&buf, uint64(len(nodes)))
+	}
+	if err == nil && len(nodes) > 0 {
+		err = encode(nodes[unsafeToStr(w.RootHash)])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Witness: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding the encoding of MarshalBinary.
+// The decoded nodes must hash to the root hash of the encoding.
+func (w *Witness) UnmarshalBinary(bz []byte) error {
+	d := &proofDecoder{bz: bz}
+	var witness Witness
+	witness.Version = d.varint()
+	witness.RootHash = d.bytes()
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.bz)) {
+		d.err = fmt.Errorf("invalid number of nodes %d", n)
+	}
+	nodes := make(map[string]*Node, n)
+
+	var decode func(maxHeight int64) *Node
+	decode = func(maxHeight int64) *Node {
+		node := &Node{}
+		height := d.varint()
+		node.version = d.varint()
+		node.key = d.bytes()
+		if d.err != nil {
+			return nil
+		}
+		if height < 0 || height > maxHeight {
+			d.err = fmt.Errorf("invalid node height %d", height)
+			return nil
+		}
+		node.subtreeHeight = int8(height)
+		if node.isLeaf() {
+			node.size = 1
+			node.value = d.bytes()
+		} else {
+			node.size = d.varint()
+			if d.err == nil && len(d.bz) == 0 {
+				d.err = errors.New("missing node flags")
+			}
+			if d.err != nil {
+				return nil
+			}
+			flags := d.bz[0]
+			d.bz = d.bz[1:]
+			if flags&^(witnessLeftChild|witnessRightChild) != 0 {
+				d.err = fmt.Errorf("invalid node flags %X", flags)
+				return nil
+			}
+			if flags&witnessLeftChild == 0 {
+				node.leftHash = d.bytes()
+			}
+			if flags&witnessRightChild == 0 {
+				node.rightHash = d.bytes()
+			}
+			if flags&witnessLeftChild != 0 {
+				if left := decode(height - 1); left != nil {
+					node.leftHash = left.hash
+				}
+			}
+			if flags&witnessRightChild != 0 {
+				if right := decode(height - 1); right != nil {
+					node.rightHash = right.hash
+				}
+			}
+			if d.err == nil && (len(node.leftHash) == 0 || len(node.rightHash) == 0) {
+				d.err = errors.New("missing child hash")
+			}
+		}
+		if d.err != nil {
+			return nil
+		}
+		hash, err := node._hash()
+		if err != nil {
+			d.err = err
+			return nil
+		}
+		if _, ok := nodes[string(hash)]; ok {
+			d.err = fmt.Errorf("duplicate node %X", hash)
+			return nil
+		}
+		nodes[string(hash)] = node
+		return node
+	}
+
+	if d.err == nil && n > 0 {
+		if root := decode(math.MaxInt8); root != nil && !bytes.Equal(root.hash, witness.RootHash) {
+			d.err = fmt.Errorf("nodes hash to %X, expected root hash %X", root.hash, witness.RootHash)
+		}
+	}
+	if d.err == nil && uint64(len(nodes)) != n {
+		d.err = fmt.Errorf("decoded %d nodes, expected %d", len(nodes), n)
+	}
+	if d.err == nil && len(d.bz) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.bz))
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode Witness: %w", d.err)
+	}
+	var err error
+	witness.Nodes, err = encodeNodes(nodes)
+	if err != nil {
+		return err
+	}
+	*w = witness
+	return nil
+}
@@ -0,0 +1,163 @@
+package iavl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+	"github.com/stretchr/testify/require"
+)
+
+// blockTree is the interface of the trees executeBlock runs on.
+type blockTree interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) (bool, error)
+	Remove(key []byte) ([]byte, bool, error)
+	Iterator(start, end []byte, ascending bool) (db.Iterator, error)
+}
+
+// executeBlock reads and changes tree like a block would, returning the values read.
+func executeBlock(t *testing.T, tree blockTree, r *rand.Rand) [][]byte {
+	var reads [][]byte
+	get := func(key []byte) {
+		value, err := tree.Get(key)
+		require.NoError(t, err)
+		reads = append(reads, value)
+	}
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%04d", r.Intn(1200)))
+		switch r.Intn(3) {
+		case 0:
+			get(key)
+		case 1:
+			_, err := tree.Set(key, []byte(fmt.Sprintf("block-%d", i)))
+			require.NoError(t, err)
+			get(key)
+		case 2:
+			_, _, err := tree.Remove(key)
+			require.NoError(t, err)
+			get(key)
+		}
+	}
+	itr, err := tree.Iterator([]byte("key0500"), []byte("key0510"), true)
+	require.NoError(t, err)
+	for ; itr.Valid(); itr.Next() {
+		reads = append(reads, itr.Key(), itr.Value())
+	}
+	require.NoError(t, itr.Close())
+	return reads
+}
+
+func TestWitness(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+		require.NoError(t, err)
+	}
+	root, version, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	require.NoError(t, tree.StartWitness())
+	require.Error(t, tree.StartWitness())
+	reads := executeBlock(t, tree, rand.New(rand.NewSource(1)))
+	newRoot, err := tree.WorkingHash()
+	require.NoError(t, err)
+	witness, err := tree.StopWitness()
+	require.NoError(t, err)
+	_, err = tree.StopWitness()
+	require.Error(t, err)
+
+	require.Equal(t, version, witness.Version)
+	require.Equal(t, root, witness.RootHash)
+	require.NoError(t, witness.Verify(root))
+	require.ErrorIs(t, witness.Verify(newRoot), ErrInvalidRoot)
+	require.Less(t, len(witness.Nodes), 2*int(tree.lastSaved.Size())-1)
+
+	// Executing the block again on the witnessed nodes alone reads the same values, and derives
+	// the same root hash.
+	witnessDB, err := newNodesDB(witness.Nodes)
+	require.NoError(t, err)
+	replay, err := newReplayTree(witnessDB, witness.RootHash, witness.Version)
+	require.NoError(t, err)
+	require.Equal(t, reads, executeBlock(t, replay, rand.New(rand.NewSource(1))))
+	replayRoot, err := replay.WorkingHash()
+	require.NoError(t, err)
+	require.Equal(t, newRoot, replayRoot)
+
+	// The binary encoding round-trips, and is smaller than the node encodings.
+	bz, err := witness.MarshalBinary()
+	require.NoError(t, err)
+	decoded := &Witness{}
+	require.NoError(t, decoded.UnmarshalBinary(bz))
+	require.Equal(t, witness, decoded)
+	size := 0
+	for _, node := range witness.Nodes {
+		size += len(node)
+	}
+	require.Less(t, len(bz), size)
+	require.Error(t, decoded.UnmarshalBinary(bz[:len(bz)-1]))
+	require.Error(t, decoded.UnmarshalBinary(append(bz, 0)))
+
+	// Nodes that are not reachable from the root are rejected.
+	other := &Witness{Version: witness.Version, RootHash: witness.RootHash, Nodes: witness.Nodes[1:]}
+	require.ErrorIs(t, other.Verify(root), ErrInvalidProof)
+	_, err = other.MarshalBinary()
+	require.ErrorIs(t, err, ErrInvalidProof)
+}
+
+func TestWitness_Versions(t *testing.T) {
+	tree, err := NewMutableTree(db.NewMemDB(), 0, false)
+	require.NoError(t, err)
+
+	// The witness of an empty tree is empty.
+	require.NoError(t, tree.StartWitness())
+	_, err = tree.Set([]byte("a"), []byte("1"))
+	require.NoError(t, err)
+	witness, err := tree.StopWitness()
+	require.NoError(t, err)
+	require.Empty(t, witness.Nodes)
+	require.NoError(t, witness.Verify(witness.RootHash))
+	bz, err := witness.MarshalBinary()
+	require.NoError(t, err)
+	decoded := &Witness{}
+	require.NoError(t, decoded.UnmarshalBinary(bz))
+	require.Equal(t, witness, decoded)
+
+	// A tree with unsaved changes cannot be witnessed.
+	require.Error(t, tree.StartWitness())
+	for i := 0; i < 100; i++ {
+		_, err := tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+		require.NoError(t, err)
+	}
+	root, _, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	// The recording carries on through saved versions, holding the nodes of the first one only.
+	require.NoError(t, tree.StartWitness())
+	_, err = tree.Set([]byte("key010"), []byte("changed"))
+	require.NoError(t, err)
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	value, err := tree.Get([]byte("key090"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+	tree.Rollback()
+	_, err = tree.Get([]byte("key050"))
+	require.NoError(t, err)
+	witness, err = tree.StopWitness()
+	require.NoError(t, err)
+	require.NoError(t, witness.Verify(root))
+	leaves := map[string]bool{}
+	for _, bz := range witness.Nodes {
+		node, err := MakeNode(bz)
+		require.NoError(t, err)
+		require.LessOrEqual(t, node.version, witness.Version)
+		if node.isLeaf() {
+			leaves[string(node.key)] = true
+		}
+	}
+	require.True(t, leaves["key090"])
+	require.True(t, leaves["key050"])
+}